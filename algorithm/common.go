@@ -9,6 +9,12 @@ type SpriteFindingAlgorithm interface {
 	FindSprites(img image.Image) []image.Rectangle
 }
 
+// BackgroundColor returns the color the algorithms treat as background: the
+// most common one in the image.
+func BackgroundColor(img image.Image) color.Color {
+	return findBgColor(img)
+}
+
 func findBgColor(img image.Image) color.Color {
 	//find most common color; this is background
 	colorFrequencies := make(map[color.Color]int)
@@ -11,9 +11,26 @@ type FloodFillAlgorithm struct {
 	MinImageHeight int
 }
 
+// Sprite is a located sprite together with the pixels that make it up:
+// its own connected components, without the background or any part of a
+// neighbouring sprite that falls inside its bounds.
+type Sprite struct {
+	Bounds image.Rectangle
+	Pixels []image.Point
+}
+
 func (a *FloodFillAlgorithm) FindSprites(img image.Image) []image.Rectangle {
+	sprites := []image.Rectangle{}
+	for _, sprite := range a.FindSpritePixels(img) {
+		sprites = append(sprites, sprite.Bounds)
+	}
+	return sprites
+}
+
+func (a *FloodFillAlgorithm) FindSpritePixels(img image.Image) []Sprite {
 	bgColor := findBgColor(img)
 	log.Printf("finding sprites in sheet %v with bg color %v", img.Bounds(), bgColor)
+	found := []Sprite{}
 	sprites := []image.Rectangle{}
 	//mark all pixels that are not bgcolor
 	marked := make(map[image.Point]bool)
@@ -30,11 +47,12 @@ func (a *FloodFillAlgorithm) FindSprites(img image.Image) []image.Rectangle {
 					}
 				}
 				sprites = append(sprites, rect)
+				found = append(found, Sprite{Bounds: rect, Pixels: sprite.pixels})
 				log.Printf("found a sprite with bounds %v; total sprites found: %v", rect, len(sprites))
 			}
 		}
 	})
-	return sprites
+	return found
 }
 
 type connectedPixels struct {
@@ -0,0 +1,201 @@
+package align
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/ilackarms/sprite-locator/models"
+)
+
+//lines up the frames of an animation, so that tight boxes of different
+//sizes, e.g. a swing whose weapon widens the box, play without wobbling.
+//Each frame becomes a trimmed region of an untrimmed frame shared by the
+//whole animation, as TexturePacker writes trimmed sprites.
+
+type Mode string
+
+const (
+	//frames are left as they are
+	None Mode = ""
+	//frames are placed so their pivots coincide
+	Pivot Mode = "pivot"
+	//each frame is placed where its pixels best overlap the previous one's
+	Correlate Mode = "correlate"
+)
+
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case None, Pivot, Correlate:
+		return m, nil
+	}
+	return None, fmt.Errorf("unknown alignment %q; use pivot or correlate", s)
+}
+
+// Options controls how frames are aligned.
+type Options struct {
+	Mode Mode
+	//pixels of this color count as transparent; nil when the sheet has alpha
+	Background color.Color
+	//largest shift, in pixels, tried between consecutive frames beyond
+	//lining up their centers; zero picks a quarter of the larger frame
+	MaxShift int
+}
+
+// Frames aligns each group of frames of atlas, given as frame indexes in
+// playing order. Every frame of a group gets the same SourceSize, and its
+// place within that frame as SpriteSourceSize. Pivots are moved to match.
+// Pivot alignment fails on frames without a pivot.
+func Frames(img image.Image, atlas *models.Atlas, groups [][]int, opts Options) error {
+	for _, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		var positions []image.Point
+		switch opts.Mode {
+		case Pivot:
+			var err error
+			if positions, err = byPivot(atlas.Frames, group); err != nil {
+				return err
+			}
+		case Correlate:
+			positions = byCorrelation(img, atlas.Frames, group, opts)
+		default:
+			continue
+		}
+		place(atlas.Frames, group, positions)
+	}
+	return nil
+}
+
+//positions are where each frame's box starts, relative to the first's
+func byPivot(frames []models.Frame, group []int) ([]image.Point, error) {
+	positions := make([]image.Point, len(group))
+	for k, f := range group {
+		frame := frames[f]
+		if frame.PivotPixel == nil {
+			return nil, fmt.Errorf("frame %v has no pivot to align on; find pivots with locate -pivot", frame.Filename)
+		}
+		//the untrimmed frame may already be larger than the box
+		pivot := image.Pt(frame.PivotPixel.X, frame.PivotPixel.Y).Sub(trimOffset(frame))
+		positions[k] = pivot.Mul(-1)
+	}
+	return positions, nil
+}
+
+func byCorrelation(img image.Image, frames []models.Frame, group []int, opts Options) []image.Point {
+	positions := make([]image.Point, len(group))
+	prev := maskOf(img, frames[group[0]], opts.Background)
+	for k := 1; k < len(group); k++ {
+		next := maskOf(img, frames[group[k]], opts.Background)
+		positions[k] = positions[k-1].Add(register(prev, next, opts.MaxShift))
+		prev = next
+	}
+	return positions
+}
+
+// place turns positions into one untrimmed frame per group.
+func place(frames []models.Frame, group []int, positions []image.Point) {
+	var bounds image.Rectangle
+	for k, f := range group {
+		box := frames[f].Box
+		rect := image.Rect(0, 0, box.W, box.H).Add(positions[k])
+		if k == 0 {
+			bounds = rect
+		} else {
+			bounds = bounds.Union(rect)
+		}
+	}
+	for k, f := range group {
+		frame := &frames[f]
+		offset := positions[k].Sub(bounds.Min)
+		//pivots are measured from the untrimmed frame
+		if frame.PivotPixel != nil {
+			pivot := image.Pt(frame.PivotPixel.X, frame.PivotPixel.Y).Sub(trimOffset(*frame)).Add(offset)
+			normalized := models.PivotAt(pivot, bounds.Size(), frame.PivotOnPixel)
+			frame.PivotPixel = &models.Point{X: pivot.X, Y: pivot.Y}
+			frame.Pivot = &normalized
+		}
+		frame.Trimmed = true
+		frame.SpriteSourceSize = models.Box{X: offset.X, Y: offset.Y, W: frame.Box.W, H: frame.Box.H}
+		frame.SourceSize = models.Size{W: bounds.Dx(), H: bounds.Dy()}
+	}
+}
+
+//where the box already sits in its untrimmed frame
+func trimOffset(frame models.Frame) image.Point {
+	if !frame.Trimmed {
+		return image.Point{}
+	}
+	return image.Pt(frame.SpriteSourceSize.X, frame.SpriteSourceSize.Y)
+}
+
+type mask struct {
+	w, h   int
+	pixels []bool
+}
+
+func (m mask) at(x, y int) bool {
+	return x >= 0 && y >= 0 && x < m.w && y < m.h && m.pixels[y*m.w+x]
+}
+
+//the frame's opaque pixels as drawn, flipped ones mirrored
+func maskOf(img image.Image, frame models.Frame, background color.Color) mask {
+	box := frame.Box
+	m := mask{w: box.W, h: box.H, pixels: make([]bool, box.W*box.H)}
+	for y := 0; y < box.H; y++ {
+		for x := 0; x < box.W; x++ {
+			sx := box.X + x
+			if frame.FlipX {
+				sx = box.X + box.W - 1 - x
+			}
+			c := img.At(sx, box.Y+y)
+			if _, _, _, a := c.RGBA(); a == 0 || (background != nil && sameColor(c, background)) {
+				continue
+			}
+			m.pixels[y*m.w+x] = true
+		}
+	}
+	return m
+}
+
+// register finds the shift of next's box relative to prev's at which the
+// most opaque pixels of both overlap. Ties go to the shift closest to
+// lining up the centers of the boxes.
+func register(prev, next mask, maxShift int) image.Point {
+	center := image.Pt((prev.w-next.w)/2, (prev.h-next.h)/2)
+	if maxShift <= 0 {
+		for _, side := range []int{prev.w, prev.h, next.w, next.h} {
+			if maxShift < side/4 {
+				maxShift = side / 4
+			}
+		}
+	}
+	best, bestScore, bestDistance := center, -1, 0
+	for dy := -maxShift; dy <= maxShift; dy++ {
+		for dx := -maxShift; dx <= maxShift; dx++ {
+			shift := center.Add(image.Pt(dx, dy))
+			score := 0
+			for y := 0; y < next.h; y++ {
+				for x := 0; x < next.w; x++ {
+					if next.pixels[y*next.w+x] && prev.at(x+shift.X, y+shift.Y) {
+						score++
+					}
+				}
+			}
+			distance := dx*dx + dy*dy
+			if score > bestScore || (score == bestScore && distance < bestDistance) {
+				best, bestScore, bestDistance = shift, score, distance
+			}
+		}
+	}
+	//next's pixel at q lies over prev's at q+shift, so next's box starts
+	//at prev's plus shift
+	return best
+}
+
+func sameColor(c1, c2 color.Color) bool {
+	r1, g1, b1, a1 := c1.RGBA()
+	r2, g2, b2, a2 := c2.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
@@ -0,0 +1,255 @@
+package animgroup
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/ilackarms/sprite-locator/algorithm"
+	"github.com/ilackarms/sprite-locator/cli"
+	"github.com/ilackarms/sprite-locator/dedup"
+	"github.com/ilackarms/sprite-locator/imagefile"
+	"github.com/ilackarms/sprite-locator/models"
+	"github.com/ilackarms/sprite-locator/output"
+)
+
+//guesses which located sprites make up which animation, so an anims file
+//only has to be checked instead of typed out index by index
+
+var Command = cli.Command{
+	Name:    "group",
+	Summary: "guess the animations of located sprites and write a draft anims file",
+	Usage:   "-boxes <boxes.json> [-image <sheet.png>] [-out <anims.json>] [-directions 8|5|1]",
+}
+
+func init() {
+	Command.Run = run
+}
+
+//directions a sheet draws, in the order its rows usually come; five
+//direction sheets leave the east-facing ones to atlas -mirror-directions
+var directionSets = map[int][]string{
+	8: models.DirectionNames,
+	5: {"S", "Sw", "W", "Nw", "N"},
+	1: {"S"},
+}
+
+type options struct {
+	directions []string
+	//perceptual hash bits consecutive frames may differ in
+	maxDistance int
+}
+
+func run(args []string) error {
+	var opts options
+	fs := cli.NewFlagSet(Command)
+	boxesPtr := fs.String("boxes", "", "boxes json file from locate")
+	imagePtr := fs.String("image", "", "sheet image; lets consecutive frames be compared pixel by pixel")
+	outPtr := fs.String("out", "", "anims json file (default <boxes>.draft.anims.json)")
+	directionsPtr := fs.Int("directions", 8, "directions each animation is drawn in: 8, 5 (s to n) or 1")
+	fs.IntVar(&opts.maxDistance, "max-distance", 20, "perceptual hash bits (of 64) consecutive frames of one animation may differ in")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+	if *boxesPtr == "" {
+		return cli.Usagef("-boxes is required")
+	}
+	opts.directions = directionSets[*directionsPtr]
+	if opts.directions == nil {
+		return cli.Usagef("-directions must be 8, 5 or 1, not %v", *directionsPtr)
+	}
+	if *outPtr == "" {
+		*outPtr = strings.TrimSuffix(*boxesPtr, ".json") + ".draft.anims.json"
+	}
+	return group(*boxesPtr, *imagePtr, *outPtr, opts)
+}
+
+func group(boxFile, imgFile, outFile string, opts options) error {
+	data, err := ioutil.ReadFile(boxFile)
+	if err != nil {
+		return err
+	}
+	var sheet models.Spritesheet
+	if err := json.Unmarshal(data, &sheet); err != nil {
+		return fmt.Errorf("failed to unmarshal spritesheet: %v", err)
+	}
+	if len(sheet.Sprites) == 0 {
+		return fmt.Errorf("%v has no sprites", boxFile)
+	}
+	var hashes []uint64
+	if imgFile != "" {
+		img, err := imagefile.Open(imgFile)
+		if err != nil {
+			return err
+		}
+		hashes = hashSprites(img, sheet.Sprites)
+	}
+
+	var runs [][]int
+	for _, row := range Rows(sheet.Sprites) {
+		runs = append(runs, SplitRow(row, sheet.Sprites, hashes, opts.maxDistance)...)
+	}
+	anims := assign(runs, sheet.Sprites, opts.directions)
+	if err := output.Write(outFile, anims.Write); err != nil {
+		return err
+	}
+	log.Printf("wrote a draft of %v runs of frames to %v; check it against the guide image before using it", len(runs), outFile)
+	return nil
+}
+
+func hashSprites(img image.Image, sprites []models.Sprite) []uint64 {
+	background := algorithm.BackgroundColor(img)
+	hashes := make([]uint64, len(sprites))
+	for i, sprite := range sprites {
+		hashes[i] = dedup.PerceptualHash(img, sprite.Rect().Intersect(img.Bounds()), background)
+	}
+	return hashes
+}
+
+// Rows groups sprites whose vertical centers line up, top to bottom, with
+// each row ordered left to right.
+func Rows(sprites []models.Sprite) [][]int {
+	order := make([]int, len(sprites))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return center(sprites[order[a]]).Y < center(sprites[order[b]]).Y
+	})
+
+	var rows [][]int
+	var sumY, sumH int
+	for _, i := range order {
+		sprite := sprites[i]
+		if n := len(rows); n > 0 {
+			row := rows[n-1]
+			meanY, meanH := sumY/len(row), sumH/len(row)
+			if abs(center(sprite).Y-meanY) <= meanH/2 {
+				rows[n-1] = append(row, i)
+				sumY += center(sprite).Y
+				sumH += height(sprite)
+				continue
+			}
+		}
+		rows = append(rows, []int{i})
+		sumY, sumH = center(sprite).Y, height(sprite)
+	}
+	for _, row := range rows {
+		sort.SliceStable(row, func(a, b int) bool {
+			return sprites[row[a]].Min.X < sprites[row[b]].Min.X
+		})
+	}
+	return rows
+}
+
+// SplitRow cuts a row of sprites into runs that look like one animation:
+// frames of a similar size, evenly spaced, and each like the one before.
+// hashes may be nil when the sheet image is not available.
+func SplitRow(row []int, sprites []models.Sprite, hashes []uint64, maxDistance int) [][]int {
+	var gaps []int
+	for k := 1; k < len(row); k++ {
+		gaps = append(gaps, sprites[row[k]].Min.X-sprites[row[k-1]].Max.X)
+	}
+	//a gap much wider than usual separates two animations
+	maxGap := 2*median(gaps) + 4
+
+	runs := [][]int{{row[0]}}
+	for k := 1; k < len(row); k++ {
+		prev, next := row[k-1], row[k]
+		same := similarSize(sprites[prev], sprites[next]) && gaps[k-1] <= maxGap
+		if same && hashes != nil {
+			same = dedup.Distance(hashes[prev], hashes[next]) <= maxDistance
+		}
+		if same {
+			runs[len(runs)-1] = append(runs[len(runs)-1], next)
+		} else {
+			runs = append(runs, []int{next})
+		}
+	}
+	return runs
+}
+
+// assign fills the animations of an anims file in order, one direction per
+// run, and logs what went where for someone to check.
+func assign(runs [][]int, sprites []models.Sprite, directions []string) models.Anims {
+	var anims models.Anims
+	slots := len(models.Animations) * len(directions)
+	for k, run := range runs {
+		if k >= slots {
+			var rest []string
+			for _, run := range runs[k:] {
+				rest = append(rest, strings.Join(models.Ranges(run), ","))
+			}
+			log.Printf("WARN: an anims file has room for %v animation directions; sprites %v were left out", slots, strings.Join(rest, " "))
+			break
+		}
+		animation := models.Animations[k/len(directions)]
+		direction := directions[k%len(directions)]
+		//runs are in playing order, which is not always sheet order; Ranges
+		//keeps that order by writing out of order sprites on their own
+		frameRange := models.Ranges(run)
+		anims.Set(animation, direction, frameRange)
+		first := sprites[run[0]]
+		log.Printf("%v.%v: %v frame(s) of about %vx%v, sprites %v", animation, direction, len(run), width(first), height(first), strings.Join(frameRange, ","))
+	}
+	checkFrameCounts(runs, directions)
+	return anims
+}
+
+//directions of one animation usually have as many frames as each other
+func checkFrameCounts(runs [][]int, directions []string) {
+	for a := 0; a < len(models.Animations) && a*len(directions) < len(runs); a++ {
+		var counts []string
+		uneven := false
+		for d := 0; d < len(directions) && a*len(directions)+d < len(runs); d++ {
+			run := runs[a*len(directions)+d]
+			counts = append(counts, fmt.Sprint(len(run)))
+			if len(run) != len(runs[a*len(directions)]) {
+				uneven = true
+			}
+		}
+		if uneven {
+			log.Printf("WARN: directions of %v have different frame counts (%v); a run may be split or merged wrongly", models.Animations[a], strings.Join(counts, ", "))
+		}
+	}
+}
+
+// similarSize reports whether neither side of one sprite is more than half
+// as long again as the other's.
+func similarSize(a, b models.Sprite) bool {
+	within := func(x, y int) bool {
+		if x < y {
+			x, y = y, x
+		}
+		return 2*x <= 3*y
+	}
+	return within(width(a), width(b)) && within(height(a), height(b))
+}
+
+//Max is the lower right pixel itself
+func width(s models.Sprite) int  { return s.Max.X - s.Min.X + 1 }
+func height(s models.Sprite) int { return s.Max.Y - s.Min.Y + 1 }
+
+func center(s models.Sprite) image.Point {
+	return image.Pt((s.Min.X+s.Max.X)/2, (s.Min.Y+s.Max.Y)/2)
+}
+
+func median(values []int) int {
+	if len(values) == 0 {
+		return 0
+	}
+	sortedValues := append([]int{}, values...)
+	sort.Ints(sortedValues)
+	return sortedValues[len(sortedValues)/2]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,131 @@
+package animsheet
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"log"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/ilackarms/sprite-locator/cli"
+	"github.com/ilackarms/sprite-locator/imagefile"
+	"github.com/ilackarms/sprite-locator/models"
+	"github.com/ilackarms/sprite-locator/output"
+)
+
+//lays the frames of an animated gif or apng out as a sheet, with the boxes
+//and timed animation the other tools read
+
+var Command = cli.Command{
+	Name:    "import",
+	Summary: "lay the frames of an animated gif or apng out as a sheet, with boxes and an animation",
+	Usage:   "[-out <sheet.png>] [-cols n] [-spacing n] [-name <animation>] <animation.gif|apng>",
+}
+
+func init() {
+	Command.Run = run
+}
+
+type options struct {
+	cols    int
+	spacing int
+	name    string
+}
+
+func run(args []string) error {
+	var opts options
+	fs := cli.NewFlagSet(Command)
+	outPtr := fs.String("out", "", "sheet image to write (default <animation>_sheet.png); boxes go to <sheet>.json and the animation to <sheet>.anim.json")
+	fs.IntVar(&opts.cols, "cols", 0, "frames per row (default as square as possible)")
+	fs.IntVar(&opts.spacing, "spacing", 0, "transparent pixels between frames")
+	fs.StringVar(&opts.name, "name", "", "animation name (default the input file name)")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return cli.Usagef("import needs exactly one animated image")
+	}
+	if opts.cols < 0 || opts.spacing < 0 {
+		return cli.Usagef("-cols and -spacing cannot be negative")
+	}
+	inFile := fs.Arg(0)
+	base := strings.TrimSuffix(inFile, filepath.Ext(inFile))
+	outFile := *outPtr
+	if outFile == "" {
+		outFile = base + "_sheet.png"
+	}
+	if !imagefile.CanEncode(outFile) {
+		return cli.Usagef("cannot write %v; use a .png, .gif, .jpg, .bmp or .tif extension", outFile)
+	}
+	if opts.name == "" {
+		opts.name = filepath.Base(base)
+	}
+
+	animated, err := imagefile.OpenAnimated(inFile)
+	if err != nil {
+		return err
+	}
+	log.Printf("decoded %v frames from %v", len(animated.Frames), inFile)
+	sheet, boxes, animation := layout(animated, opts)
+
+	outBase := strings.TrimSuffix(outFile, filepath.Ext(outFile))
+	if err := imagefile.Save(outFile, sheet); err != nil {
+		return err
+	}
+	if err := output.Write(outBase+".json", func(w io.Writer) error {
+		return writeBoxes(w, boxes)
+	}); err != nil {
+		return err
+	}
+	if err := output.Write(outBase+".anim.json", animation.Write); err != nil {
+		return err
+	}
+	log.Printf("wrote %v, %v.json and %v.anim.json", outFile, outBase, outBase)
+	return nil
+}
+
+// layout copies every frame into its own cell, left to right and top to
+// bottom. Each box covers the whole cell, so the frames stay registered
+// against each other.
+func layout(animated imagefile.Animated, opts options) (*image.RGBA, models.Spritesheet, models.Animation) {
+	count := len(animated.Frames)
+	cols := opts.cols
+	if cols == 0 {
+		cols = int(math.Ceil(math.Sqrt(float64(count))))
+	}
+	if cols > count {
+		cols = count
+	}
+	rows := (count + cols - 1) / cols
+	frameSize := animated.Frames[0].Bounds().Size()
+	cellWidth, cellHeight := frameSize.X+opts.spacing, frameSize.Y+opts.spacing
+	sheet := image.NewRGBA(image.Rect(0, 0, cols*cellWidth-opts.spacing, rows*cellHeight-opts.spacing))
+
+	var boxes models.Spritesheet
+	animation := models.Animation{Name: opts.name, Loops: animated.Loops}
+	for i, frame := range animated.Frames {
+		min := image.Pt(i%cols*cellWidth, i/cols*cellHeight)
+		cell := image.Rectangle{Min: min, Max: min.Add(frameSize)}
+		draw.Draw(sheet, cell, frame, frame.Bounds().Min, draw.Src)
+		boxes.Sprites = append(boxes.Sprites, models.Sprite{
+			Min: models.Point{X: cell.Min.X, Y: cell.Min.Y},
+			//locate reports the lower right pixel itself
+			Max: models.Point{X: cell.Max.X - 1, Y: cell.Max.Y - 1},
+		})
+		animation.Frames = append(animation.Frames, models.AnimationFrame{Sprite: i, Delay: animated.Delays[i]})
+	}
+	return sheet, boxes, animation
+}
+
+func writeBoxes(w io.Writer, boxes models.Spritesheet) error {
+	data, err := json.Marshal(boxes)
+	if err != nil {
+		return fmt.Errorf("marshalling sprite sheet metadata: %v", err)
+	}
+	_, err = w.Write(data)
+	return err
+}
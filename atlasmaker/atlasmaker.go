@@ -1,229 +1,299 @@
-package main
+package atlasmaker
 
 import (
 	"os"
 	"log"
 	"io/ioutil"
 	"github.com/ilackarms/sprite-locator/models"
-	"encoding/json"
+	"github.com/ilackarms/sprite-locator/cli"
+	"github.com/ilackarms/sprite-locator/batch"
+	"github.com/ilackarms/sprite-locator/watch"
+	"github.com/ilackarms/sprite-locator/naming"
+	"github.com/ilackarms/sprite-locator/output"
+	"github.com/ilackarms/sprite-locator/align"
+	"github.com/ilackarms/sprite-locator/algorithm"
+	"github.com/ilackarms/sprite-locator/imagefile"
+	"path/filepath"
+	"runtime"
 	"strings"
-	"strconv"
+	"encoding/json"
 	"fmt"
+	"image"
 )
 
-func main(){
-	boxFile := os.Args[1]
-	animFile := os.Args[2]
-	boxData, err := ioutil.ReadFile(boxFile)
-	must(err)
-	animData, err := ioutil.ReadFile(animFile)
-	must(err)
-	var boxes models.Spritesheet
-	must(json.Unmarshal(boxData, &boxes))
-	var anims Anims
-	must(json.Unmarshal(animData, &anims))
-	var atlas Atlas
-	addRange(&atlas, boxes, "Attack.S", anims.Attack.S)
-	addRange(&atlas, boxes, "Attack.Sw", anims.Attack.Sw)
-	addRange(&atlas, boxes, "Attack.W", anims.Attack.W)
-	addRange(&atlas, boxes, "Attack.Nw", anims.Attack.Nw)
-	addRange(&atlas, boxes, "Attack.N", anims.Attack.N)
-	addRange(&atlas, boxes, "Attack.Ne", anims.Attack.Ne)
-	addRange(&atlas, boxes, "Attack.E", anims.Attack.E)
-	addRange(&atlas, boxes, "Attack.Se", anims.Attack.Se)
-
-	addRange(&atlas, boxes, "Die.S", anims.Die.S)
-	addRange(&atlas, boxes, "Die.Sw", anims.Die.Sw)
-	addRange(&atlas, boxes, "Die.W", anims.Die.W)
-	addRange(&atlas, boxes, "Die.Nw", anims.Die.Nw)
-	addRange(&atlas, boxes, "Die.N", anims.Die.N)
-	addRange(&atlas, boxes, "Die.Ne", anims.Die.Ne)
-	addRange(&atlas, boxes, "Die.E", anims.Die.E)
-	addRange(&atlas, boxes, "Die.Se", anims.Die.Se)
-
-	addRange(&atlas, boxes, "GetHit.S", anims.GetHit.S)
-	addRange(&atlas, boxes, "GetHit.Sw", anims.GetHit.Sw)
-	addRange(&atlas, boxes, "GetHit.W", anims.GetHit.W)
-	addRange(&atlas, boxes, "GetHit.Nw", anims.GetHit.Nw)
-	addRange(&atlas, boxes, "GetHit.N", anims.GetHit.N)
-	addRange(&atlas, boxes, "GetHit.Ne", anims.GetHit.Ne)
-	addRange(&atlas, boxes, "GetHit.E", anims.GetHit.E)
-	addRange(&atlas, boxes, "GetHit.Se", anims.GetHit.Se)
-
-	addRange(&atlas, boxes, "Idle.S", anims.Idle.S)
-	addRange(&atlas, boxes, "Idle.Sw", anims.Idle.Sw)
-	addRange(&atlas, boxes, "Idle.W", anims.Idle.W)
-	addRange(&atlas, boxes, "Idle.Nw", anims.Idle.Nw)
-	addRange(&atlas, boxes, "Idle.N", anims.Idle.N)
-	addRange(&atlas, boxes, "Idle.Ne", anims.Idle.Ne)
-	addRange(&atlas, boxes, "Idle.E", anims.Idle.E)
-	addRange(&atlas, boxes, "Idle.Se", anims.Idle.Se)
-
-	addRange(&atlas, boxes, "Spell.S", anims.Spell.S)
-	addRange(&atlas, boxes, "Spell.Sw", anims.Spell.Sw)
-	addRange(&atlas, boxes, "Spell.W", anims.Spell.W)
-	addRange(&atlas, boxes, "Spell.Nw", anims.Spell.Nw)
-	addRange(&atlas, boxes, "Spell.N", anims.Spell.N)
-	addRange(&atlas, boxes, "Spell.Ne", anims.Spell.Ne)
-	addRange(&atlas, boxes, "Spell.E", anims.Spell.E)
-	addRange(&atlas, boxes, "Spell.Se", anims.Spell.Se)
-
-	addRange(&atlas, boxes, "Walk.S", anims.Walk.S)
-	addRange(&atlas, boxes, "Walk.Sw", anims.Walk.Sw)
-	addRange(&atlas, boxes, "Walk.W", anims.Walk.W)
-	addRange(&atlas, boxes, "Walk.Nw", anims.Walk.Nw)
-	addRange(&atlas, boxes, "Walk.N", anims.Walk.N)
-	addRange(&atlas, boxes, "Walk.Ne", anims.Walk.Ne)
-	addRange(&atlas, boxes, "Walk.E", anims.Walk.E)
-	addRange(&atlas, boxes, "Walk.Se", anims.Walk.Se)
-
-	log.Printf("%+v", atlas)
+var Command = cli.Command{
+	Name:    "atlas",
+	Summary: "name located boxes after the animations in an anims file and write a frame atlas",
+	Usage:   "[-out <atlas.json>] [-watch] [-align pivot|correlate -image <sheet.png>] -boxes <boxes.json> -anims <anims.json> | [-anims <anims.json>] [-out-dir <dir>] <boxes, directory or pattern>...",
+}
 
-	raw, err := json.Marshal(atlas)
-	must(err)
-	fmt.Print(string(raw))
+func init() {
+	Command.Run = run
 }
 
-func addRange(atlas *Atlas, boxes models.Spritesheet, animationName string, frameRange []string) {
-	frameCount := 1
-	for _, r := range frameRange {
-		if strings.Contains(r, "..") {
-			split := strings.Split(r, "..")
-			b := split[0]
-			e := split[1]
-			begin, err := strconv.Atoi(b)
-			must(err)
-			end, err := strconv.Atoi(e)
-			must(err)
-			for i := begin; i <= end; i++ {
-				frameName := fmt.Sprintf("%s%04d", animationName, frameCount)
-				frame := getFrame(frameName, boxes, i)
-				atlas.Frames = append(atlas.Frames, frame)
-				frameCount++
-			}
-		} else {
-			i, err := strconv.Atoi(r)
-			must(err)
-			frameName := fmt.Sprintf("%s%04d", animationName, frameCount)
-			frame := getFrame(frameName, boxes, i)
-			atlas.Frames = append(atlas.Frames, frame)
-			frameCount++
+func run(args []string) error {
+	fs := cli.NewFlagSet(Command)
+	boxesPtr := fs.String("boxes", "", "boxes json file")
+	animsPtr := fs.String("anims", "", "anims json file; in batch mode each <boxes>.anims.json is used when this is not set")
+	outPtr := fs.String("out", "", "atlas json file (default stdout)")
+	outDirPtr := fs.String("out-dir", "", "batch mode: write atlases into a tree mirroring the inputs instead of next to them")
+	jobsPtr := fs.Int("jobs", runtime.NumCPU(), "batch mode: boxes files processed at once")
+	watching, watchOpts := watch.Flags(fs)
+	namePtr := fs.String("name", defaultName, "frame name template; fields are {sheet} (boxes file name), {anim}, {dir}, {frame} (counting from 1) and {index} (sprite index)")
+	var opts atlasOptions
+	fs.BoolVar(&opts.mirrorDirections, "mirror-directions", false, "fill in a direction the anims file leaves empty by mirroring the opposite one, e.g. Ne from Nw")
+	fs.BoolVar(&opts.nearDuplicates, "near-duplicates", false, "also give frames of sprites that match by perceptual hash (exact false in the boxes json) the region of their first copy, not just identical ones")
+	alignPtr := fs.String("align", "", "line up the frames of each animation in a shared untrimmed frame, written as spriteSourceSize: pivot (on the pivots from locate -pivot) or correlate (where their pixels overlap most)")
+	fs.StringVar(&opts.imageFile, "image", "", "sheet image the boxes belong to; needed by -align correlate")
+	fs.IntVar(&opts.align.MaxShift, "max-shift", 0, "align correlate: largest shift in pixels tried between consecutive frames (default a quarter of the frame)")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+	tmpl, err := naming.Parse(*namePtr, naming.Sheet, naming.Anim, naming.Dir, naming.Frame, naming.Index)
+	if err != nil {
+		return cli.Usagef("-name: %v", err)
+	}
+	opts.names = naming.Names{Template: tmpl, Custom: *namePtr != defaultName}
+	if opts.align.Mode, err = align.ParseMode(*alignPtr); err != nil {
+		return cli.Usagef("-align: %v", err)
+	}
+	if opts.align.Mode == align.Correlate && opts.imageFile == "" {
+		return cli.Usagef("-align correlate needs the sheet given with -image")
+	}
+	//atlasmaker <boxes.json> <anims.json> predates the flags
+	if *boxesPtr == "" && *animsPtr == "" && fs.NArg() == 2 {
+		*boxesPtr, *animsPtr = fs.Arg(0), fs.Arg(1)
+	} else if *boxesPtr == "" && fs.NArg() > 0 {
+		if *outPtr != "" {
+			return cli.Usagef("-out names the atlas of a single boxes file; use -out-dir for several")
 		}
+		if *watching {
+			return cli.Usagef("-watch works on a single boxes file given with -boxes")
+		}
+		if opts.imageFile != "" {
+			return cli.Usagef("-image belongs to a single boxes file given with -boxes")
+		}
+		return runBatch(fs.Args(), *animsPtr, *outDirPtr, *jobsPtr, opts)
+	}
+	if *boxesPtr == "" || *animsPtr == "" {
+		return cli.Usagef("-boxes and -anims are required")
+	}
+	if !*watching {
+		return writeAtlas(*boxesPtr, *animsPtr, *outPtr, opts)
 	}
+	if *outPtr == "" {
+		*outPtr = strings.TrimSuffix(*boxesPtr, filepath.Ext(*boxesPtr)) + ".atlas.json"
+	}
+	paths := []string{*boxesPtr, *animsPtr}
+	if opts.imageFile != "" {
+		paths = append(paths, opts.imageFile)
+	}
+	return watch.Run(paths, *watchOpts, func() error {
+		return writeAtlas(*boxesPtr, *animsPtr, *outPtr, opts)
+	})
+}
+
+//writes to stdout when outFile is empty
+func writeAtlas(boxFile, animFile, outFile string, opts atlasOptions) error {
+	atlas, err := makeAtlas(boxFile, animFile, opts)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("%+v", atlas)
+
+	if outFile == "" {
+		return atlas.Write(os.Stdout)
+	}
+	return output.Write(outFile, atlas.Write)
+}
+
+//boxes files are the json files that are not anims, atlases, or the offsets
+//and animations written by extract and import
+func isBoxesFile(path string) bool {
+	return filepath.Ext(path) == ".json" &&
+		!strings.HasSuffix(path, ".anims.json") &&
+		!strings.HasSuffix(path, ".anim.json") &&
+		!strings.HasSuffix(path, ".atlas.json") &&
+		!strings.HasSuffix(path, ".offsets.json")
 }
 
-func getFrame(frameName string, boxes models.Spritesheet, i int) Frame {
-	box := boxes.Sprites[i]
-	x0 := box.Min.X
-	y0 := box.Min.Y
-	w := box.Max.X - x0
-	h := box.Max.Y - y0
-
-	return Frame{
-		Filename: frameName,
-		Box: Box{
-			X: x0,
-			Y: y0,
-			W: w,
-			H: h,
-		},
+// runBatch writes <boxes>.atlas.json for every boxes file matched by args.
+func runBatch(args []string, animFile, outDir string, jobs int, opts atlasOptions) error {
+	inputs, err := batch.Expand(args, isBoxesFile)
+	if err != nil {
+		return err
 	}
+	results := batch.Run(inputs, jobs, func(in batch.Input) batch.Result {
+		anims := animFile
+		if anims == "" {
+			anims = strings.TrimSuffix(in.Path, ".json") + ".anims.json"
+		}
+		atlas, err := makeAtlas(in.Path, anims, opts)
+		if err != nil {
+			return batch.Result{Err: err}
+		}
+		outFile, err := batch.OutputPath(in, outDir, ".atlas.json")
+		if err != nil {
+			return batch.Result{Err: err}
+		}
+		if err := output.Write(outFile, atlas.Write); err != nil {
+			return batch.Result{Err: err}
+		}
+		return batch.Result{Output: outFile, Count: len(atlas.Frames)}
+	})
+	return batch.PrintSummary(os.Stdout, results, "frames")
 }
 
-func must(err error) {
+//the name atlasmaker has always given frames, e.g. Attack.S0001
+const defaultName = "{anim}.{dir}{frame:04}"
+
+type atlasOptions struct {
+	names naming.Names
+	//synthesize empty directions from their mirror image
+	mirrorDirections bool
+	//give frames of near duplicates their first copy's region too
+	nearDuplicates bool
+	align          align.Options
+	//sheet image, for aligning by correlation
+	imageFile string
+}
+
+func makeAtlas(boxFile, animFile string, opts atlasOptions) (models.Atlas, error) {
+	boxData, err := ioutil.ReadFile(boxFile)
 	if err != nil {
-		log.Fatal(err)
+		return models.Atlas{}, err
 	}
+	animData, err := ioutil.ReadFile(animFile)
+	if err != nil {
+		return models.Atlas{}, err
+	}
+	var boxes models.Spritesheet
+	if err := json.Unmarshal(boxData, &boxes); err != nil {
+		return models.Atlas{}, fmt.Errorf("failed to unmarshal spritesheet: %v", err)
+	}
+	var anims models.Anims
+	if err := json.Unmarshal(animData, &anims); err != nil {
+		return models.Atlas{}, fmt.Errorf("failed to unmarshal anims: %v", err)
+	}
+	if err := anims.Validate(len(boxes.Sprites)); err != nil {
+		return models.Atlas{}, err
+	}
+	sheet := strings.TrimSuffix(filepath.Base(boxFile), filepath.Ext(boxFile))
+	b := builder{
+		boxes:     boxes,
+		canonical: boxes.Canonical(opts.nearDuplicates),
+		names:     opts.names.Template,
+	}
+	anims.EachDirection(func(animation, direction string, frameRange []string) {
+		fields := naming.Fields{Sheet: sheet, Anim: animation, Dir: direction}
+		if len(frameRange) > 0 || !opts.mirrorDirections {
+			b.addRange(fields, frameRange, "")
+			return
+		}
+		mirror, ok := models.MirrorDirection(direction)
+		if !ok {
+			return
+		}
+		if mirrorRange := anims.Directions(animation).Get(mirror); len(mirrorRange) > 0 {
+			b.addRange(fields, mirrorRange, mirror)
+		}
+	})
+	b.flipMirrors()
+
+	var filenames []string
+	for _, frame := range b.atlas.Frames {
+		filenames = append(filenames, frame.Filename)
+	}
+	if err := opts.names.Check(filenames); err != nil {
+		return models.Atlas{}, fmt.Errorf("frame names from %q: %v", opts.names, err)
+	}
+	if opts.align.Mode != align.None {
+		if err := alignFrames(&b, opts); err != nil {
+			return models.Atlas{}, err
+		}
+	}
+	return b.atlas, nil
 }
 
-type Atlas struct {
-	Frames []Frame `json:"frames"`
+//aligns the frames of each animation direction
+func alignFrames(b *builder, opts atlasOptions) error {
+	var img image.Image
+	if opts.imageFile != "" {
+		var err error
+		if img, err = imagefile.Open(opts.imageFile); err != nil {
+			return err
+		}
+		opts.align.Background = algorithm.BackgroundColor(img)
+	}
+	if err := align.Frames(img, &b.atlas, b.groups, opts.align); err != nil {
+		return err
+	}
+	log.Printf("aligned the frames of %v animations by %v", len(b.groups), opts.align.Mode)
+	return nil
 }
 
-type Frame struct {
-	Filename string `json:"filename"`
-	Box Box `json:"frame"`
-	Rotated bool `json:"rotated"`
-	Trimmed bool `json:"trimmed"`
-	SpriteSourceSize struct {
-			 X int `json:"x"`
-			 Y int `json:"y"`
-			 W int `json:"w"`
-			 H int `json:"h"`
-		 } `json:"spriteSourceSize"`
-	SourceSize struct {
-			 W int `json:"w"`
-			 H int `json:"h"`
-		 } `json:"sourceSize"`
+type builder struct {
+	atlas models.Atlas
+	//sprite index behind every frame
+	sprites []int
+	//frame indexes of each animation direction
+	groups    [][]int
+	boxes     models.Spritesheet
+	canonical []int
+	names     naming.Template
 }
 
-type Box struct {
-	X int `json:"x"`
-	Y int `json:"y"`
-	W int `json:"w"`
-	H int `json:"h"`
+// addRange adds a frame for every sprite of frameRange. Given a mirror
+// direction, the frames are that direction's flipped, as when Ne is drawn
+// from Nw. Ranges were checked by anims.Validate.
+func (b *builder) addRange(fields naming.Fields, frameRange []string, mirror string) {
+	indexes, _ := models.Indexes(frameRange)
+	var group []int
+	for frameCount, i := range indexes {
+		fields.Frame = frameCount + 1
+		fields.Index = i
+		//frames showing a duplicate sprite share the region of its first copy
+		frame := models.NewFrame(b.names.Execute(fields), models.BoxOf(b.boxes.Sprites[b.canonical[i]]))
+		frame.PivotFrom(b.boxes.Sprites[b.canonical[i]], mirror != "")
+		if mirror != "" {
+			mirrored := fields
+			mirrored.Dir = mirror
+			frame.FlipX = true
+			frame.MirrorOf = b.names.Execute(mirrored)
+		}
+		group = append(group, len(b.atlas.Frames))
+		b.atlas.Frames = append(b.atlas.Frames, frame)
+		b.sprites = append(b.sprites, i)
+	}
+	if len(group) > 0 {
+		b.groups = append(b.groups, group)
+	}
 }
 
-type Anims struct {
-	Attack struct {
-		       S []string `json:"s"`
-		       Sw []string `json:"sw"`
-		       W []string `json:"w"`
-		       Nw []string `json:"nw"`
-		       N []string `json:"n"`
-		       Ne []string `json:"ne"`
-		       E []string `json:"e"`
-		       Se []string `json:"se"`
-	       } `json:"attack"`
-	Idle struct {
-		       S []string `json:"s"`
-		       Sw []string `json:"sw"`
-		       W []string `json:"w"`
-		       Nw []string `json:"nw"`
-		       N []string `json:"n"`
-		       Ne []string `json:"ne"`
-		       E []string `json:"e"`
-		       Se []string `json:"se"`
-	       } `json:"idle"`
-	Walk struct {
-		       S []string `json:"s"`
-		       Sw []string `json:"sw"`
-		       W []string `json:"w"`
-		       Nw []string `json:"nw"`
-		       N []string `json:"n"`
-		       Ne []string `json:"ne"`
-		       E []string `json:"e"`
-		       Se []string `json:"se"`
-	       } `json:"walk"`
-	GetHit struct {
-		       S []string `json:"s"`
-		       Sw []string `json:"sw"`
-		       W []string `json:"w"`
-		       Nw []string `json:"nw"`
-		       N []string `json:"n"`
-		       Ne []string `json:"ne"`
-		       E []string `json:"e"`
-		       Se []string `json:"se"`
-	       } `json:"get_hit"`
-	Die struct {
-		       S []string `json:"s"`
-		       Sw []string `json:"sw"`
-		       W []string `json:"w"`
-		       Nw []string `json:"nw"`
-		       N []string `json:"n"`
-		       Ne []string `json:"ne"`
-		       E []string `json:"e"`
-		       Se []string `json:"se"`
-	       } `json:"die"`
-	Spell struct {
-		       S []string `json:"s"`
-		       Sw []string `json:"sw"`
-		       W []string `json:"w"`
-		       Nw []string `json:"nw"`
-		       N []string `json:"n"`
-		       Ne []string `json:"ne"`
-		       E []string `json:"e"`
-		       Se []string `json:"se"`
-	       } `json:"spell"`
-
-}
\ No newline at end of file
+// flipMirrors points frames showing a sprite listed as a mirror at the
+// region of its original, drawn flipped.
+func (b *builder) flipMirrors() {
+	if len(b.boxes.Mirrors) == 0 {
+		return
+	}
+	mirroredOf := b.boxes.MirroredOf()
+	firstFrame := make(map[int]string)
+	for f, i := range b.sprites {
+		if _, ok := firstFrame[b.canonical[i]]; !ok && !b.atlas.Frames[f].FlipX {
+			firstFrame[b.canonical[i]] = b.atlas.Frames[f].Filename
+		}
+	}
+	for f, i := range b.sprites {
+		frame := &b.atlas.Frames[f]
+		of := mirroredOf[b.canonical[i]]
+		if of < 0 || frame.FlipX {
+			continue
+		}
+		original := b.canonical[of]
+		frame.Box = models.BoxOf(b.boxes.Sprites[original])
+		frame.PivotFrom(b.boxes.Sprites[original], true)
+		frame.FlipX = true
+		frame.MirrorOf = firstFrame[original]
+	}
+}
@@ -0,0 +1,68 @@
+package atlasmaker
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ilackarms/sprite-locator/naming"
+)
+
+//testdata/boxes.atlas.json was written by atlasmaker before it became a
+//subcommand, then rewritten once when frame boxes started to include the
+//sprite's Max pixel; the default output must not change otherwise
+
+func defaultOptions(t *testing.T) atlasOptions {
+	tmpl, err := naming.Parse(defaultName, naming.Sheet, naming.Anim, naming.Dir, naming.Frame, naming.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return atlasOptions{names: naming.Names{Template: tmpl}}
+}
+
+func TestGolden(t *testing.T) {
+	atlas, err := makeAtlas("testdata/boxes.json", "testdata/anims.json", defaultOptions(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	if err := atlas.Write(&got); err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile("testdata/boxes.atlas.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("atlas differs from testdata/boxes.atlas.json:\ngot  %.200s\nwant %.200s", got.Bytes(), want)
+	}
+}
+
+func TestOutOfRangeIndex(t *testing.T) {
+	anims := filepath.Join(t.TempDir(), "anims.json")
+	if err := ioutil.WriteFile(anims, []byte(`{"attack": {"s": ["0..2", "9999"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := makeAtlas("testdata/boxes.json", anims, defaultOptions(t))
+	if err == nil || !strings.Contains(err.Error(), "9999") {
+		t.Errorf("expected an error naming index 9999, got %v", err)
+	}
+}
+
+func TestIsBoxesFile(t *testing.T) {
+	for path, want := range map[string]bool{
+		"hero.json":         true,
+		"hero.anims.json":   false,
+		"hero.atlas.json":   false,
+		"hero.offsets.json": false,
+		"hero.anim.json":    false,
+		"hero.png":          false,
+		"dir/hero.json":     true,
+	} {
+		if got := isBoxesFile(path); got != want {
+			t.Errorf("isBoxesFile(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
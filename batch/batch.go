@@ -0,0 +1,206 @@
+package batch
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+//runs one command over many inputs: files, directories and glob patterns,
+//with outputs written next to each input or into a mirror tree
+
+// Input is one file to process.
+type Input struct {
+	Path string
+	//directory the argument that matched Path started from; a mirror tree
+	//keeps Path's location relative to it
+	Root string
+}
+
+// Result is the outcome of processing one Input.
+type Result struct {
+	Input  Input
+	Output string
+	//sprites or frames found, shown in the summary
+	Count int
+	Err   error
+}
+
+// Expand turns command line arguments into inputs. Files are taken as they
+// are, directories are walked for files accepted by match, and patterns
+// containing *, ? or [ are globbed. Each file appears once, in argument
+// order.
+func Expand(args []string, match func(path string) bool) ([]Input, error) {
+	var inputs []Input
+	seen := make(map[string]bool)
+	add := func(path, root string) {
+		path = filepath.Clean(path)
+		if seen[path] {
+			return
+		}
+		seen[path] = true
+		inputs = append(inputs, Input{Path: path, Root: root})
+	}
+	for _, arg := range args {
+		if strings.ContainsAny(arg, "*?[") {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("bad pattern %v: %v", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("%v matches no files", arg)
+			}
+			root := globRoot(arg)
+			for _, m := range matches {
+				if info, err := os.Stat(m); err == nil && !info.IsDir() {
+					add(m, root)
+				}
+			}
+			continue
+		}
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			add(arg, filepath.Dir(arg))
+			continue
+		}
+		var found []string
+		err = filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && match(path) {
+				found = append(found, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("walking %v: %v", arg, err)
+		}
+		sort.Strings(found)
+		for _, path := range found {
+			add(path, arg)
+		}
+	}
+	return inputs, nil
+}
+
+//the directory part of a pattern before its first wildcard
+func globRoot(pattern string) string {
+	i := strings.IndexAny(pattern, "*?[")
+	return filepath.Dir(pattern[:i] + "x")
+}
+
+// OutputPath returns where the output for in goes: next to it when outDir
+// is empty, otherwise at the same place relative to outDir as in is to its
+// root. The input's extension is replaced by ext. Parent directories of a
+// mirrored path are created.
+func OutputPath(in Input, outDir, ext string) (string, error) {
+	out := outputName(in, outDir, ext)
+	if outDir == "" {
+		return out, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return "", fmt.Errorf("creating %v: %v", filepath.Dir(out), err)
+	}
+	return out, nil
+}
+
+func outputName(in Input, outDir, ext string) string {
+	name := strings.TrimSuffix(in.Path, filepath.Ext(in.Path)) + ext
+	if outDir == "" {
+		return name
+	}
+	rel, err := filepath.Rel(in.Root, name)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(name)
+	}
+	return filepath.Join(outDir, rel)
+}
+
+// CheckOutputs fails if two inputs would write the same output, such as
+// a.png and a.gif both writing a.json, rather than letting the later one
+// silently replace the first.
+func CheckOutputs(inputs []Input, outDir, ext string) error {
+	written := make(map[string]string)
+	var collisions []string
+	for _, in := range inputs {
+		out := outputName(in, outDir, ext)
+		if first, ok := written[out]; ok {
+			collisions = append(collisions, fmt.Sprintf("%v and %v both write %v", first, in.Path, out))
+			continue
+		}
+		written[out] = in.Path
+	}
+	if len(collisions) > 0 {
+		return fmt.Errorf("outputs collide; rename the inputs or process them separately: %v", strings.Join(collisions, "; "))
+	}
+	return nil
+}
+
+// Run calls fn for every input on at most workers goroutines and returns
+// the results in input order.
+func Run(inputs []Input, workers int, fn func(in Input) Result) []Result {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]Result, len(inputs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(inputs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = call(fn, inputs[i])
+				results[i].Input = inputs[i]
+			}
+		}()
+	}
+	for i := range inputs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+//a panic on one input is reported as its failure rather than ending the batch
+func call(fn func(in Input) Result, in Input) (result Result) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = Result{Err: fmt.Errorf("panic: %v", r)}
+		}
+	}()
+	return fn(in)
+}
+
+// PrintSummary writes a table of every result followed by totals, and
+// returns an error naming how many inputs failed, if any did.
+func PrintSummary(w io.Writer, results []Result, countName string) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "INPUT\tSTATUS\t%s\tOUTPUT\n", strings.ToUpper(countName))
+	var failed, total int
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			fmt.Fprintf(tw, "%s\tFAILED\t-\t%v\n", r.Input.Path, r.Err)
+			continue
+		}
+		total += r.Count
+		fmt.Fprintf(tw, "%s\tok\t%d\t%s\n", r.Input.Path, r.Count, r.Output)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\n%d succeeded, %d failed, %d %s in total\n", len(results)-failed, failed, total, countName)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d inputs failed", failed, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ilackarms/sprite-locator/output"
+)
+
+// Command is one sprite-locator subcommand.
+type Command struct {
+	Name string
+	//one line shown in the command list
+	Summary string
+	//argument synopsis shown after the command name, e.g. "[flags] <image>"
+	Usage string
+	Run   func(args []string) error
+}
+
+// UsageError is returned by a command whose arguments are wrong; main
+// prints the command's help and exits with status 2 instead of 1.
+type UsageError struct {
+	msg string
+}
+
+func (e UsageError) Error() string {
+	return e.msg
+}
+
+func Usagef(format string, args ...interface{}) error {
+	return UsageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// NewFlagSet returns a flag set that reports errors to its caller rather
+// than exiting, so every command fails the same way. Every command writes
+// files, so every flag set has -force and -no-clobber.
+func NewFlagSet(cmd Command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	output.Flags(fs)
+	fs.Usage = func() {
+		PrintUsage(fs.Output(), cmd, fs)
+	}
+	return fs
+}
+
+// Parse parses args, turning bad flags into a UsageError. -h and --help
+// print the command's usage to stdout and are passed through as
+// flag.ErrHelp.
+func Parse(fs *flag.FlagSet, args []string) error {
+	fs.SetOutput(io.Discard)
+	err := fs.Parse(args)
+	fs.SetOutput(os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		fs.SetOutput(os.Stdout)
+		fs.Usage()
+		fs.SetOutput(os.Stderr)
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	if err != nil {
+		return UsageError{msg: err.Error()}
+	}
+	if err := output.FromFlags(fs); err != nil {
+		return UsageError{msg: err.Error()}
+	}
+	return nil
+}
+
+func PrintUsage(w io.Writer, cmd Command, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "usage: sprite-locator %s %s\n\n%s\n", cmd.Name, cmd.Usage, cmd.Summary)
+	if fs == nil {
+		return
+	}
+	var hasFlags bool
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprintf(w, "\nflags:\n")
+		fs.SetOutput(w)
+		fs.PrintDefaults()
+		fs.SetOutput(os.Stderr)
+	}
+}
+
+// EnvString returns the value of an environment variable, or def if it is
+// unset. Env vars only provide defaults; flags always win.
+func EnvString(env, def string) string {
+	if v := os.Getenv(env); v != "" {
+		return v
+	}
+	return def
+}
+
+func EnvInt(env string, def int) (int, error) {
+	v := os.Getenv(env)
+	if v == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a valid integer. unset %s or give a valid value", v, env)
+	}
+	return i, nil
+}
+
+// EnvBool treats any value other than "", "false" and "0" as true.
+func EnvBool(env string, def bool) bool {
+	v := strings.TrimSpace(os.Getenv(env))
+	if v == "" {
+		return def
+	}
+	return v != "false" && v != "0"
+}
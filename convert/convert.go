@@ -0,0 +1,293 @@
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ilackarms/sprite-locator/cli"
+	"github.com/ilackarms/sprite-locator/formats"
+	"github.com/ilackarms/sprite-locator/imagefile"
+	"github.com/ilackarms/sprite-locator/output"
+)
+
+//converts between locator boxes, the atlasmaker/sheetsplitter frame atlas
+//and the formats game engines import
+
+type format struct {
+	read  func(r io.Reader, in input) (formats.Texture, error)
+	write func(w io.Writer, t formats.Texture) error
+	//sidecar mapping names to layout indexes, for formats that have one
+	writeIndex func(w io.Writer, t formats.Texture) error
+	keeps      formats.Features
+}
+
+// what a reader may need besides the file itself
+type input struct {
+	//base name of the input file, used to name regions of unnamed formats
+	name string
+	size image.Point
+}
+
+var (
+	cssOptions   formats.CSSOptions
+	tiledOptions formats.TiledOptions
+	tableNames   bool
+)
+
+var supported = map[string]format{
+	"boxes": {
+		read: func(r io.Reader, in input) (formats.Texture, error) {
+			return formats.ReadSpritesheet(r, in.name)
+		},
+		write: formats.WriteSpritesheet,
+		keeps: formats.Features{Pivots: true},
+	},
+	"atlas": {
+		read:  readOnly(formats.ReadAtlas),
+		write: formats.WriteAtlas,
+		keeps: formats.Features{Names: true, Pivots: true, Rotation: true, Trimming: true, Flips: true},
+	},
+	"unity": {
+		read: func(r io.Reader, in input) (formats.Texture, error) {
+			if in.size.Y == 0 {
+				return formats.Texture{}, fmt.Errorf("-image is required to read unity rects")
+			}
+			return formats.ReadUnityMeta(r, in.size.Y)
+		},
+		write: formats.WriteUnityMeta,
+		keeps: formats.Features{Names: true, Pivots: true, Borders: true},
+	},
+	"starling": {
+		read:  readOnly(formats.ReadStarlingXML),
+		write: formats.WriteStarlingXML,
+		keeps: formats.Features{Names: true, Pivots: true, Rotation: true, Trimming: true},
+	},
+	"cocos2": {
+		read: readOnly(formats.ReadCocosPlist),
+		write: func(w io.Writer, t formats.Texture) error {
+			return formats.WriteCocosPlist(w, t, 2)
+		},
+		keeps: formats.Features{Names: true, Rotation: true, Trimming: true},
+	},
+	"cocos3": {
+		read: readOnly(formats.ReadCocosPlist),
+		write: func(w io.Writer, t formats.Texture) error {
+			return formats.WriteCocosPlist(w, t, 3)
+		},
+		keeps: formats.Features{Names: true, Rotation: true, Trimming: true},
+	},
+	"css": {
+		write: func(w io.Writer, t formats.Texture) error {
+			return formats.WriteCSS(w, t, cssOptions)
+		},
+		keeps: formats.Features{Names: true},
+	},
+	"tiled": {
+		read: readOnly(formats.ReadTiledTileset),
+		write: func(w io.Writer, t formats.Texture) error {
+			return formats.WriteTiledTileset(w, t, tiledOptions)
+		},
+		keeps: formats.Features{Names: true},
+	},
+	"bevy": {
+		read: func(r io.Reader, in input) (formats.Texture, error) {
+			return formats.ReadBevyLayout(r, in.name)
+		},
+		write:      formats.WriteBevyRON,
+		writeIndex: formats.WriteBevyIndexRON,
+	},
+	"bevy-json": {
+		read: func(r io.Reader, in input) (formats.Texture, error) {
+			return formats.ReadBevyLayout(r, in.name)
+		},
+		write:      formats.WriteBevyJSON,
+		writeIndex: formats.WriteBevyIndexJSON,
+	},
+	"csv": {
+		read: func(r io.Reader, in input) (formats.Texture, error) {
+			return formats.ReadCSV(r, ',', in.name)
+		},
+		write: func(w io.Writer, t formats.Texture) error {
+			return formats.WriteCSV(w, t, formats.CSVOptions{Comma: ',', Names: tableNames})
+		},
+		keeps: formats.Features{Names: true},
+	},
+	"tsv": {
+		read: func(r io.Reader, in input) (formats.Texture, error) {
+			return formats.ReadCSV(r, '\t', in.name)
+		},
+		write: func(w io.Writer, t formats.Texture) error {
+			return formats.WriteCSV(w, t, formats.CSVOptions{Comma: '\t', Names: tableNames})
+		},
+		keeps: formats.Features{Names: true},
+	},
+}
+
+// formats.Detect does not tell the cocos plist versions apart; both read the same
+var readAs = map[string]string{
+	"cocos": "cocos3",
+}
+
+var Command = cli.Command{
+	Name:    "convert",
+	Summary: "convert boxes or an atlas between the formats game engines import",
+	Usage:   "[-from <format>] -to <" + formatNames(false) + "> [-image <sheet.png>] [-out <file>] [-html <preview.html>] [-index <names.ron>] <input-file>",
+}
+
+func init() {
+	Command.Run = run
+}
+
+func run(args []string) error {
+	fs := cli.NewFlagSet(Command)
+	fromPtr := fs.String("from", "", "input format (default detected from the content): "+formatNames(true))
+	toPtr := fs.String("to", "", "output format: "+formatNames(false))
+	imagePtr := fs.String("image", "", "sheet image the boxes belong to")
+	outPtr := fs.String("out", "", "output file (default stdout)")
+	htmlPtr := fs.String("html", "", "css only: also write a preview page of every sprite to this file")
+	indexPtr := fs.String("index", "", "bevy only: also write the sidecar mapping sprite names and animations to layout indexes")
+	fs.StringVar(&cssOptions.Class, "class", "sprite", "css only: class shared by every sprite")
+	fs.BoolVar(&cssOptions.Pixelated, "pixelated", false, "css only: render scaled sprites with image-rendering: pixelated")
+	fs.BoolVar(&cssOptions.Retina, "2x", false, "css only: on high density screens, draw the sprites from <image>@2x, a copy of the sheet at twice the size")
+	fs.StringVar(&tiledOptions.Name, "tileset", "", "tiled only: tileset name (default the image name)")
+	fs.IntVar(&tiledOptions.FrameDuration, "duration", 100, "tiled only: milliseconds per animation frame")
+	fs.BoolVar(&tableNames, "names", false, "csv and tsv only: add name and animation columns")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return cli.Usagef("convert needs exactly one input file")
+	}
+	if supported[*toPtr].write == nil {
+		return cli.Usagef("unknown output format %q; -to must be one of %v", *toPtr, formatNames(false))
+	}
+	return convert(fs.Arg(0), *fromPtr, *toPtr, *imagePtr, *outPtr, *htmlPtr, *indexPtr)
+}
+
+func convert(inFile, from, to, imgFile, outFile, htmlFile, indexFile string) error {
+	in := input{name: strings.TrimSuffix(filepath.Base(inFile), filepath.Ext(inFile))}
+	if imgFile != "" {
+		size, err := imagefile.Size(imgFile)
+		if err != nil {
+			return err
+		}
+		in.size = size
+	}
+
+	data, err := ioutil.ReadFile(inFile)
+	if err != nil {
+		return fmt.Errorf("reading %v: %v", inFile, err)
+	}
+	if from == "" {
+		detected, err := formats.Detect(data)
+		if err != nil {
+			return fmt.Errorf("detecting format of %v: %v; set -from", inFile, err)
+		}
+		from = detected
+		log.Printf("%v looks like %v", inFile, from)
+	}
+	if alias, ok := readAs[from]; ok {
+		from = alias
+	}
+	read := supported[from].read
+	if read == nil {
+		return fmt.Errorf("cannot read %v; readable formats are %v", from, formatNames(true))
+	}
+	texture, err := read(bytes.NewReader(data), in)
+	if err != nil {
+		return fmt.Errorf("reading %v as %v: %v", inFile, from, err)
+	}
+	if imgFile != "" {
+		texture.Image = imgFile
+		texture.Size = in.size
+	}
+	if texture.Image == "" {
+		texture.Image = in.name + ".png"
+	}
+
+	if indexFile != "" && supported[to].writeIndex == nil {
+		return fmt.Errorf("-to %v has no index sidecar", to)
+	}
+	keeps := supported[to].keeps
+	if (to == "csv" || to == "tsv") && !tableNames {
+		keeps.Names = false
+	}
+	if indexFile != "" {
+		//the sidecar holds the names the layout cannot
+		keeps.Names = true
+	}
+	dropped := formats.Dropped(texture, keeps)
+	for _, lost := range dropped {
+		log.Printf("WARN: %v cannot store %v; dropping them", to, lost)
+	}
+	if len(dropped) > 0 {
+		log.Printf("%v keeps %v", to, keeps)
+	}
+
+	log.Printf("writing %v regions as %v", len(texture.Regions), to)
+	write := func(w io.Writer) error {
+		return supported[to].write(w, texture)
+	}
+	if outFile == "" {
+		err = write(os.Stdout)
+	} else {
+		err = output.Write(outFile, write)
+	}
+	if err != nil {
+		return err
+	}
+	if htmlFile != "" {
+		if to != "css" || outFile == "" {
+			return fmt.Errorf("-html needs -to css and an -out file to link to")
+		}
+		if err := writeHTML(htmlFile, outFile, texture); err != nil {
+			return err
+		}
+	}
+	if indexFile != "" {
+		writeIndex := supported[to].writeIndex
+		log.Printf("writing name index to %v", indexFile)
+		return output.Write(indexFile, func(w io.Writer) error {
+			return writeIndex(w, texture)
+		})
+	}
+	return nil
+}
+
+func writeHTML(htmlFile, cssFile string, texture formats.Texture) error {
+	stylesheet, err := filepath.Rel(filepath.Dir(htmlFile), cssFile)
+	if err != nil {
+		stylesheet = cssFile
+	}
+	log.Printf("writing sprite preview to %v", htmlFile)
+	return output.Write(htmlFile, func(w io.Writer) error {
+		return formats.WriteSpriteHTML(w, texture, filepath.ToSlash(stylesheet), cssOptions)
+	})
+}
+
+func readOnly(read func(r io.Reader) (formats.Texture, error)) func(r io.Reader, in input) (formats.Texture, error) {
+	return func(r io.Reader, in input) (formats.Texture, error) {
+		return read(r)
+	}
+}
+
+func formatNames(readable bool) string {
+	var names []string
+	for name, f := range supported {
+		if readable && f.read == nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "|")
+}
@@ -0,0 +1,157 @@
+package crop
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+//cuts single sprites out of a sheet into their own images
+
+// Options controls the canvas each sprite is drawn onto. The zero value
+// crops tightly to the sprite.
+type Options struct {
+	//transparent pixels added on every side
+	Padding int
+	//grow the canvas to the next power of two in each dimension
+	PowerOfTwo bool
+	//draw every sprite onto a canvas of this size; zero crops to the sprite.
+	//sprites larger than the cell grow the canvas
+	Cell image.Point
+	//where a sprite sits in a canvas larger than itself
+	Anchor Anchor
+	//pixels of this color become transparent; nil keeps them
+	Background color.Color
+	//when set, only pixels for which it returns true are copied; the rest
+	//of the sprite's box is left transparent
+	Mask func(x, y int) bool
+}
+
+type Anchor string
+
+const (
+	Center       Anchor = "center"
+	BottomCenter Anchor = "bottom"
+	TopLeft      Anchor = "top-left"
+)
+
+func ParseAnchor(s string) (Anchor, error) {
+	switch a := Anchor(s); a {
+	case Center, BottomCenter, TopLeft:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown anchor %q; use center, bottom or top-left", s)
+}
+
+// ParseSize parses a cell size written as WxH, e.g. 64x96.
+func ParseSize(s string) (image.Point, error) {
+	parts := strings.Split(strings.ToLower(s), "x")
+	if len(parts) != 2 {
+		return image.Point{}, fmt.Errorf("size %q is not WxH", s)
+	}
+	w, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return image.Point{}, fmt.Errorf("size %q is not WxH", s)
+	}
+	h, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return image.Point{}, fmt.Errorf("size %q is not WxH", s)
+	}
+	if w <= 0 || h <= 0 {
+		return image.Point{}, fmt.Errorf("size %q must be positive", s)
+	}
+	return image.Pt(w, h), nil
+}
+
+// Offset records where an extracted sprite came from and where it was
+// drawn in its own image, so it can be put back in place.
+type Offset struct {
+	File string `json:"file"`
+	//the sprite's box on the sheet
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+	//top-left of the sprite inside the extracted image
+	OffsetX int `json:"offset_x"`
+	OffsetY int `json:"offset_y"`
+	//size of the extracted image
+	CanvasW int `json:"canvas_w"`
+	CanvasH int `json:"canvas_h"`
+	//File shows this sprite mirrored left to right
+	FlipX bool `json:"flip_x,omitempty"`
+}
+
+// Sprite copies the pixels of rect onto a new canvas sized by opts and
+// returns it with the offset of rect's top-left corner inside it.
+func Sprite(src image.Image, rect image.Rectangle, opts Options) (*image.RGBA, image.Point) {
+	rect = rect.Intersect(src.Bounds())
+	size := rect.Size()
+	canvas := size
+	if opts.Cell.X > canvas.X {
+		canvas.X = opts.Cell.X
+	}
+	if opts.Cell.Y > canvas.Y {
+		canvas.Y = opts.Cell.Y
+	}
+	canvas = canvas.Add(image.Pt(2*opts.Padding, 2*opts.Padding))
+	if opts.PowerOfTwo {
+		canvas = image.Pt(nextPowerOfTwo(canvas.X), nextPowerOfTwo(canvas.Y))
+	}
+
+	free := canvas.Sub(size)
+	var offset image.Point
+	switch opts.Anchor {
+	case Center:
+		offset = image.Pt(free.X/2, free.Y/2)
+	case BottomCenter:
+		offset = image.Pt(free.X/2, free.Y-opts.Padding)
+	default:
+		offset = image.Pt(opts.Padding, opts.Padding)
+	}
+
+	img := image.NewRGBA(image.Rectangle{Max: canvas})
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if opts.Mask != nil && !opts.Mask(x, y) {
+				continue
+			}
+			c := src.At(x, y)
+			if opts.Background != nil && sameColor(c, opts.Background) {
+				continue
+			}
+			img.Set(x-rect.Min.X+offset.X, y-rect.Min.Y+offset.Y, c)
+		}
+	}
+	return img, offset
+}
+
+// WriteOffsets writes the sidecar listing every extracted sprite.
+func WriteOffsets(w io.Writer, offsets []Offset) error {
+	data, err := json.MarshalIndent(struct {
+		Sprites []Offset `json:"sprites"`
+	}{offsets}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling offsets: %v", err)
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p *= 2
+	}
+	return p
+}
+
+func sameColor(c1, c2 color.Color) bool {
+	r1, g1, b1, a1 := c1.RGBA()
+	r2, g2, b2, a2 := c2.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
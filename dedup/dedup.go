@@ -0,0 +1,277 @@
+package dedup
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"math/bits"
+	"sort"
+
+	"github.com/ilackarms/sprite-locator/models"
+)
+
+//finds sprites that show the same frame; ripped sheets often repeat a frame
+//many times, and each copy only needs to be extracted or packed once
+
+// Options controls when two sprites count as the same.
+type Options struct {
+	//pixels of this color count as transparent, so a frame matches itself
+	//on sheets with a solid background; nil when the sheet has alpha
+	Background color.Color
+	//largest perceptual hash distance, in bits out of 64, at which sprites
+	//still match; negative matches identical pixels only
+	MaxDistance int
+}
+
+// DefaultMaxDistance catches boxes a pixel apart without matching
+// different frames of one animation.
+const DefaultMaxDistance = 4
+
+//boxes more than this many pixels apart in either dimension never match
+const maxSizeDifference = 2
+
+type hashed struct {
+	size       image.Point
+	exact      uint64
+	perceptual uint64
+	//exact hash of the pixels mirrored left to right
+	flipped uint64
+}
+
+// Find groups the sprites at rects of img that show the same frame. Groups
+// list sprite indexes in ascending order, and sprites without a duplicate
+// are left out.
+func Find(img image.Image, rects []image.Rectangle, opts Options) []models.DuplicateGroup {
+	hashes := make([]hashed, len(rects))
+	for i, rect := range rects {
+		rect = rect.Intersect(img.Bounds())
+		hashes[i] = hashed{
+			size:       rect.Size(),
+			exact:      exactHash(img, rect, opts.Background, false),
+			perceptual: perceptualHash(img, rect, opts.Background, false),
+			flipped:    exactHash(img, rect, opts.Background, true),
+		}
+	}
+
+	//each sprite joins the group of an identical sprite, or else the first
+	//group whose first sprite it nearly matches; comparing with the first
+	//sprite only keeps near matches from chaining through a whole animation
+	var members [][]int
+	byExact := make(map[hashed]int)
+	for i, h := range hashes {
+		key := hashed{size: h.size, exact: h.exact}
+		group, ok := byExact[key]
+		if !ok && opts.MaxDistance >= 0 {
+			for g, sprites := range members {
+				if near(hashes[sprites[0]], h, opts.MaxDistance) {
+					group, ok = g, true
+					break
+				}
+			}
+		}
+		if !ok {
+			group = len(members)
+			members = append(members, nil)
+		}
+		members[group] = append(members[group], i)
+		byExact[key] = group
+	}
+
+	var groups []models.DuplicateGroup
+	for _, sprites := range members {
+		if len(sprites) < 2 {
+			continue
+		}
+		exact := true
+		for _, i := range sprites[1:] {
+			if hashes[i].size != hashes[sprites[0]].size || hashes[i].exact != hashes[sprites[0]].exact {
+				exact = false
+			}
+		}
+		groups = append(groups, models.DuplicateGroup{Sprites: sprites, Exact: exact})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Sprites[0] < groups[j].Sprites[0]
+	})
+	return groups
+}
+
+func near(a, b hashed, maxDistance int) bool {
+	if abs(a.size.X-b.size.X) > maxSizeDifference || abs(a.size.Y-b.size.Y) > maxSizeDifference {
+		return false
+	}
+	//an exact mirror image is a mirror, not a near duplicate, even when its
+	//silhouette is symmetric
+	if a.size == b.size && a.exact == b.flipped && a.exact != b.exact {
+		return false
+	}
+	return Distance(a.perceptual, b.perceptual) <= maxDistance
+}
+
+// FindMirrors finds sprites that show another one flipped left to right.
+// Each mirror refers to the lowest sprite it matches that is not a mirror
+// itself, preferring exact matches. Symmetric sprites, which match their own
+// mirror image, are left out, as are plain duplicates.
+func FindMirrors(img image.Image, rects []image.Rectangle, opts Options) []models.Mirror {
+	plain := make([]hashed, len(rects))
+	flipped := make([]hashed, len(rects))
+	for i, rect := range rects {
+		rect = rect.Intersect(img.Bounds())
+		plain[i] = hashed{
+			size:       rect.Size(),
+			exact:      exactHash(img, rect, opts.Background, false),
+			perceptual: perceptualHash(img, rect, opts.Background, false),
+			flipped:    exactHash(img, rect, opts.Background, true),
+		}
+		flipped[i] = hashed{
+			size:       rect.Size(),
+			exact:      plain[i].flipped,
+			perceptual: perceptualHash(img, rect, opts.Background, true),
+			flipped:    plain[i].exact,
+		}
+	}
+	same := func(a, b hashed) bool {
+		return a.size == b.size && a.exact == b.exact
+	}
+
+	mirrorOf := make([]int, len(rects))
+	var mirrors []models.Mirror
+	for j := range rects {
+		mirrorOf[j] = -1
+		if same(plain[j], flipped[j]) {
+			continue
+		}
+		//a candidate original: not a mirror itself, nor a copy of j
+		candidate := func(i int) bool {
+			return mirrorOf[i] < 0 && !same(plain[i], plain[j]) &&
+				!(opts.MaxDistance >= 0 && near(plain[i], plain[j], opts.MaxDistance))
+		}
+		for i := 0; i < j && mirrorOf[j] < 0; i++ {
+			if candidate(i) && same(plain[i], flipped[j]) {
+				mirrorOf[j] = i
+				mirrors = append(mirrors, models.Mirror{Sprite: j, Of: i, Exact: true})
+			}
+		}
+		//near matches are only trusted for sprites that are clearly not
+		//symmetric, or every roughly symmetric sprite would mirror another
+		if mirrorOf[j] >= 0 || opts.MaxDistance < 0 || near(plain[j], flipped[j], opts.MaxDistance) {
+			continue
+		}
+		for i := 0; i < j; i++ {
+			//the hash's 8x8 cells are not laid out symmetrically on boxes
+			//whose width is not a multiple of 8, so i flipped may match j
+			//when j flipped does not match i
+			if candidate(i) && (near(plain[i], flipped[j], opts.MaxDistance) || near(flipped[i], plain[j], opts.MaxDistance)) {
+				mirrorOf[j] = i
+				mirrors = append(mirrors, models.Mirror{Sprite: j, Of: i})
+				break
+			}
+		}
+	}
+	return mirrors
+}
+
+// ExactHash hashes the pixels of rect, with background and fully
+// transparent pixels all counting as the same.
+func ExactHash(img image.Image, rect image.Rectangle, background color.Color) uint64 {
+	return exactHash(img, rect, background, false)
+}
+
+//flip reads the pixels of rect mirrored left to right
+func exactHash(img image.Image, rect image.Rectangle, background color.Color, flip bool) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	binary.LittleEndian.PutUint32(buf[:4], uint32(rect.Dx()))
+	binary.LittleEndian.PutUint32(buf[4:], uint32(rect.Dy()))
+	h.Write(buf[:])
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			c := color.NRGBAModel.Convert(pixel(img, rect, x, y, background, flip)).(color.NRGBA)
+			if c.A == 0 {
+				c = color.NRGBA{}
+			}
+			h.Write([]byte{c.R, c.G, c.B, c.A})
+		}
+	}
+	return h.Sum64()
+}
+
+// PerceptualHash shrinks rect to 8x8 and sets a bit for every cell with more
+// ink than average, so sprites whose boxes are off by a pixel or whose
+// colors differ slightly hash alike.
+func PerceptualHash(img image.Image, rect image.Rectangle, background color.Color) uint64 {
+	return perceptualHash(img, rect, background, false)
+}
+
+func perceptualHash(img image.Image, rect image.Rectangle, background color.Color, flip bool) uint64 {
+	var cells [64]float64
+	w, h := rect.Dx(), rect.Dy()
+	if w == 0 || h == 0 {
+		return 0
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			r, g, b, a := pixel(img, rect, x, y, background, flip).RGBA()
+			if a == 0 {
+				continue
+			}
+			//luma of the premultiplied color, with a floor so dark pixels
+			//still count as ink
+			luma := (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 0xffff
+			ink := 0.25*float64(a)/0xffff + 0.75*luma
+			cx := (x - rect.Min.X) * 8 / w
+			cy := (y - rect.Min.Y) * 8 / h
+			cells[cy*8+cx] += ink
+		}
+	}
+	//cells of a small sprite cover different numbers of pixels
+	for i := range cells {
+		cx, cy := i%8, i/8
+		area := float64(((cx+1)*w+7)/8-(cx*w+7)/8) * float64(((cy+1)*h+7)/8-(cy*h+7)/8)
+		if area > 0 {
+			cells[i] /= area
+		}
+	}
+	var mean float64
+	for _, v := range cells {
+		mean += v
+	}
+	mean /= 64
+	var hash uint64
+	for i, v := range cells {
+		if v > mean {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// Distance counts the bits two perceptual hashes differ in.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+func pixel(img image.Image, rect image.Rectangle, x, y int, background color.Color, flip bool) color.Color {
+	if flip {
+		x = rect.Max.X - 1 - (x - rect.Min.X)
+	}
+	c := img.At(x, y)
+	if background != nil && sameColor(c, background) {
+		return color.Transparent
+	}
+	return c
+}
+
+func sameColor(c1, c2 color.Color) bool {
+	r1, g1, b1, a1 := c1.RGBA()
+	r2, g2, b2, a2 := c2.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
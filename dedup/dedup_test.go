@@ -0,0 +1,171 @@
+package dedup
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"reflect"
+	"testing"
+
+	"github.com/ilackarms/sprite-locator/models"
+)
+
+var (
+	black = color.NRGBA{A: 255}
+	red   = color.NRGBA{R: 255, A: 255}
+)
+
+const spriteSize = 16
+
+//a sprite is painted rectangles in a 16x16 box
+type stroke struct {
+	rect image.Rectangle
+	c    color.Color
+}
+
+//a flag on a pole, clearly not symmetric
+var flag = []stroke{
+	{image.Rect(2, 1, 4, 15), black},
+	{image.Rect(4, 1, 13, 7), red},
+	{image.Rect(1, 14, 7, 15), black},
+}
+
+//a cross, the same mirrored
+var cross = []stroke{
+	{image.Rect(7, 1, 9, 15), black},
+	{image.Rect(2, 6, 14, 10), red},
+}
+
+// sheet lays sprites out in 24 pixel slots on a white background.
+type sheet struct {
+	img   *image.NRGBA
+	rects []image.Rectangle
+}
+
+func newSheet(slots int) *sheet {
+	img := image.NewNRGBA(image.Rect(0, 0, slots*24, 24))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	return &sheet{img: img}
+}
+
+//add paints strokes, mirrored left to right if mirror is set, into the
+//next slot and boxes them, grown by grow pixels on the left
+func (s *sheet) add(strokes []stroke, mirror bool, grow int) int {
+	origin := image.Pt(len(s.rects)*24+4, 4)
+	for _, st := range strokes {
+		r := st.rect
+		if mirror {
+			r = image.Rect(spriteSize-r.Max.X, r.Min.Y, spriteSize-r.Min.X, r.Max.Y)
+		}
+		draw.Draw(s.img, r.Add(origin), image.NewUniform(st.c), image.Point{}, draw.Src)
+	}
+	box := image.Rectangle{Min: origin, Max: origin.Add(image.Pt(spriteSize, spriteSize))}
+	box.Min.X -= grow
+	s.rects = append(s.rects, box)
+	return len(s.rects) - 1
+}
+
+func (s *sheet) options(maxDistance int) Options {
+	return Options{Background: color.White, MaxDistance: maxDistance}
+}
+
+func TestFindExactAndNear(t *testing.T) {
+	s := newSheet(4)
+	s.add(flag, false, 0)
+	s.add(cross, false, 0)
+	s.add(flag, false, 0)
+	//the same frame, boxed a pixel wider
+	s.add(flag, false, 1)
+
+	got := Find(s.img, s.rects, s.options(-1))
+	want := []models.DuplicateGroup{{Sprites: []int{0, 2}, Exact: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exact only: got %+v, want %+v", got, want)
+	}
+
+	got = Find(s.img, s.rects, s.options(DefaultMaxDistance))
+	want = []models.DuplicateGroup{{Sprites: []int{0, 2, 3}, Exact: false}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("near: got %+v, want %+v", got, want)
+	}
+}
+
+func TestFindThreshold(t *testing.T) {
+	s := newSheet(2)
+	s.add(flag, false, 0)
+	//the flag a little longer
+	s.add(append([]stroke{{image.Rect(13, 1, 15, 7), red}}, flag...), false, 0)
+	distance := Distance(
+		PerceptualHash(s.img, s.rects[0], color.White),
+		PerceptualHash(s.img, s.rects[1], color.White))
+	if distance == 0 {
+		t.Fatal("the longer flag hashes like the short one")
+	}
+	if groups := Find(s.img, s.rects, s.options(distance)); len(groups) != 1 {
+		t.Errorf("sprites %v bits apart not grouped at a %v bit threshold: %+v", distance, distance, groups)
+	}
+	if groups := Find(s.img, s.rects, s.options(distance-1)); len(groups) != 0 {
+		t.Errorf("sprites %v bits apart grouped at a %v bit threshold: %+v", distance, distance-1, groups)
+	}
+}
+
+func TestSymmetricSprites(t *testing.T) {
+	s := newSheet(3)
+	s.add(cross, false, 0)
+	//the cross mirrored is the same cross
+	s.add(cross, true, 0)
+	s.add(flag, false, 0)
+
+	got := Find(s.img, s.rects, s.options(DefaultMaxDistance))
+	want := []models.DuplicateGroup{{Sprites: []int{0, 1}, Exact: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if mirrors := FindMirrors(s.img, s.rects, s.options(DefaultMaxDistance)); len(mirrors) != 0 {
+		t.Errorf("symmetric sprites reported as mirrors: %+v", mirrors)
+	}
+}
+
+func TestMirrorIsNotDuplicate(t *testing.T) {
+	//a cross with one extra pixel hashes close to its own mirror image
+	nearlySymmetric := append([]stroke{{image.Rect(1, 6, 2, 7), black}}, cross...)
+	s := newSheet(2)
+	s.add(nearlySymmetric, false, 0)
+	s.add(nearlySymmetric, true, 0)
+
+	if groups := Find(s.img, s.rects, s.options(DefaultMaxDistance)); len(groups) != 0 {
+		t.Errorf("a mirror image grouped as a duplicate: %+v", groups)
+	}
+	got := FindMirrors(s.img, s.rects, s.options(DefaultMaxDistance))
+	want := []models.Mirror{{Sprite: 1, Of: 0, Exact: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestFindNearMirrors(t *testing.T) {
+	s := newSheet(3)
+	s.add(flag, false, 0)
+	s.add(cross, false, 0)
+	//the flag mirrored, boxed a pixel wider
+	s.add(flag, true, 1)
+
+	got := FindMirrors(s.img, s.rects, s.options(DefaultMaxDistance))
+	want := []models.Mirror{{Sprite: 2, Of: 0, Exact: false}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if mirrors := FindMirrors(s.img, s.rects, s.options(-1)); len(mirrors) != 0 {
+		t.Errorf("exact only: a mirror a pixel off matched: %+v", mirrors)
+	}
+
+	//exactly mirrored, it is found as exact
+	s = newSheet(2)
+	s.add(flag, false, 0)
+	s.add(flag, true, 0)
+	got = FindMirrors(s.img, s.rects, s.options(-1))
+	want = []models.Mirror{{Sprite: 1, Of: 0, Exact: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exact mirror: got %+v, want %+v", got, want)
+	}
+}
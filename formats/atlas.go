@@ -0,0 +1,111 @@
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"math"
+
+	"github.com/ilackarms/sprite-locator/models"
+)
+
+// ReadAtlas decodes the frame atlas json written by atlasmaker and sheetsplitter.
+func ReadAtlas(r io.Reader) (Texture, error) {
+	atlas, err := models.ReadAtlas(r)
+	if err != nil {
+		return Texture{}, err
+	}
+	var t Texture
+	for _, frame := range atlas.Frames {
+		box := frame.Box
+		t.Regions = append(t.Regions, Region{
+			Name:         frame.Filename,
+			Rect:         image.Rect(box.X, box.Y, box.X+box.W, box.Y+box.H),
+			Rotated:      frame.Rotated,
+			Trimmed:      frame.Trimmed,
+			Offset:       image.Pt(frame.SpriteSourceSize.X, frame.SpriteSourceSize.Y),
+			SourceSize:   image.Pt(frame.SourceSize.W, frame.SourceSize.H),
+			FlipX:        frame.FlipX,
+			MirrorOf:     frame.MirrorOf,
+			Pivot:        pivotOf(frame.Pivot),
+			PivotOnPixel: frame.PivotOnPixel,
+		})
+	}
+	return t, nil
+}
+
+// WriteAtlas encodes a Texture in the atlasmaker/sheetsplitter json format.
+func WriteAtlas(w io.Writer, t Texture) error {
+	var atlas models.Atlas
+	for _, region := range t.Regions {
+		frame := models.NewFrame(region.Name, models.Box{
+			X: region.Rect.Min.X,
+			Y: region.Rect.Min.Y,
+			W: region.Rect.Dx(),
+			H: region.Rect.Dy(),
+		})
+		frame.Rotated = region.Rotated
+		frame.Trimmed = region.Trimmed
+		frame.FlipX = region.FlipX
+		frame.MirrorOf = region.MirrorOf
+		if region.Trimmed {
+			frame.SpriteSourceSize = models.Box{
+				X: region.Offset.X,
+				Y: region.Offset.Y,
+				W: region.Rect.Dx(),
+				H: region.Rect.Dy(),
+			}
+			frame.SourceSize = models.Size{W: region.Source().X, H: region.Source().Y}
+		}
+		if region.Pivot != nil {
+			frame.Pivot = &models.Pivot{X: region.Pivot.X, Y: region.Pivot.Y}
+			frame.PivotPixel = pivotPixel(*region.Pivot, region.Source(), region.PivotOnPixel)
+			frame.PivotOnPixel = region.PivotOnPixel
+		}
+		atlas.Frames = append(atlas.Frames, frame)
+	}
+	return atlas.Write(w)
+}
+
+// ReadSpritesheet decodes locator boxes json.
+func ReadSpritesheet(r io.Reader, name string) (Texture, error) {
+	var sheet models.Spritesheet
+	if err := json.NewDecoder(r).Decode(&sheet); err != nil {
+		return Texture{}, fmt.Errorf("decoding spritesheet: %v", err)
+	}
+	return FromSpritesheet(name, sheet), nil
+}
+
+// WriteSpritesheet encodes a Texture as locator boxes json.
+func WriteSpritesheet(w io.Writer, t Texture) error {
+	data, err := json.Marshal(t.Spritesheet())
+	if err != nil {
+		return fmt.Errorf("marshalling sprite sheet metadata: %v", err)
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func pivotOf(p *models.Pivot) *Pivot {
+	if p == nil {
+		return nil
+	}
+	return &Pivot{X: p.X, Y: p.Y}
+}
+
+//a normalized pivot in pixels from the upper left corner of a source this
+//size; a pivot on a pixel is normalized to its center, so it is the pixel
+//the pivot falls in rather than the nearest line between pixels
+func pivotPixel(p Pivot, size image.Point, onPixel bool) *models.Point {
+	if onPixel {
+		return &models.Point{
+			X: int(math.Floor(p.X * float64(size.X))),
+			Y: int(math.Floor(p.Y * float64(size.Y))),
+		}
+	}
+	return &models.Point{
+		X: int(math.Round(p.X * float64(size.X))),
+		Y: int(math.Round(p.Y * float64(size.Y))),
+	}
+}
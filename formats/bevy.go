@@ -0,0 +1,167 @@
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// bevy's TextureAtlasLayout: the texture size plus one URect per region,
+// with glam vectors serialized as [x, y]
+type bevyLayout struct {
+	Size     [2]int     `json:"size"`
+	Textures []bevyRect `json:"textures"`
+}
+
+type bevyRect struct {
+	Min [2]int `json:"min"`
+	Max [2]int `json:"max"`
+}
+
+// maps names to indexes in bevyLayout.Textures, which carry no names themselves
+type bevyIndex struct {
+	Names      map[string]int   `json:"names"`
+	Animations map[string][]int `json:"animations"`
+}
+
+func toBevyLayout(t Texture) (bevyLayout, error) {
+	if t.Size == (image.Point{}) {
+		return bevyLayout{}, fmt.Errorf("texture size is required to write a bevy layout")
+	}
+	layout := bevyLayout{Size: [2]int{t.Size.X, t.Size.Y}, Textures: []bevyRect{}}
+	for _, region := range t.Regions {
+		if region.Rotated {
+			return bevyLayout{}, fmt.Errorf("region %v is rotated; bevy atlas rects cannot be rotated", region.Name)
+		}
+		layout.Textures = append(layout.Textures, bevyRect{
+			Min: [2]int{region.Rect.Min.X, region.Rect.Min.Y},
+			Max: [2]int{region.Rect.Max.X, region.Rect.Max.Y},
+		})
+	}
+	return layout, nil
+}
+
+// WriteBevyJSON encodes a Texture as a json TextureAtlasLayout.
+func WriteBevyJSON(w io.Writer, t Texture) error {
+	layout, err := toBevyLayout(t)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, layout)
+}
+
+// WriteBevyRON encodes a Texture as a ron TextureAtlasLayout, loadable
+// with bevy's ron asset loaders.
+func WriteBevyRON(w io.Writer, t Texture) error {
+	layout, err := toBevyLayout(t)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "(\n")
+	fmt.Fprintf(&b, "    size: (%v, %v),\n", layout.Size[0], layout.Size[1])
+	fmt.Fprintf(&b, "    textures: [\n")
+	for _, rect := range layout.Textures {
+		fmt.Fprintf(&b, "        (min: (%v, %v), max: (%v, %v)),\n", rect.Min[0], rect.Min[1], rect.Max[0], rect.Max[1])
+	}
+	fmt.Fprintf(&b, "    ],\n")
+	fmt.Fprintf(&b, ")\n")
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// WriteBevyIndexJSON writes the json sidecar mapping region names and
+// animations to indexes of the layout written by WriteBevyJSON.
+func WriteBevyIndexJSON(w io.Writer, t Texture) error {
+	index := bevyIndex{Names: make(map[string]int), Animations: make(map[string][]int)}
+	for i, region := range t.Regions {
+		index.Names[region.Name] = i
+	}
+	for _, animation := range t.Animations() {
+		index.Animations[animation.Name] = animation.Frames
+	}
+	return writeJSON(w, index)
+}
+
+// WriteBevyIndexRON writes the ron sidecar mapping region names and
+// animations to indexes of the layout written by WriteBevyRON.
+func WriteBevyIndexRON(w io.Writer, t Texture) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "(\n")
+	fmt.Fprintf(&b, "    names: {\n")
+	for i, region := range t.Regions {
+		fmt.Fprintf(&b, "        %s: %v,\n", strconv.Quote(region.Name), i)
+	}
+	fmt.Fprintf(&b, "    },\n")
+	fmt.Fprintf(&b, "    animations: {\n")
+	for _, animation := range t.Animations() {
+		var frames []string
+		for _, frame := range animation.Frames {
+			frames = append(frames, strconv.Itoa(frame))
+		}
+		fmt.Fprintf(&b, "        %s: [%s],\n", strconv.Quote(animation.Name), strings.Join(frames, ", "))
+	}
+	fmt.Fprintf(&b, "    },\n")
+	fmt.Fprintf(&b, ")\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var ronInteger = regexp.MustCompile(`-?\d+`)
+
+// ReadBevyLayout decodes a TextureAtlasLayout in either json or ron, which
+// starts with (. The layout does not name its rects, so regions are named
+// <name>0000, ...
+func ReadBevyLayout(r io.Reader, name string) (Texture, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Texture{}, fmt.Errorf("reading bevy layout: %v", err)
+	}
+	var layout bevyLayout
+	if !strings.HasPrefix(strings.TrimSpace(string(data)), "(") {
+		if err := json.Unmarshal(data, &layout); err != nil {
+			return Texture{}, fmt.Errorf("unmarshalling bevy layout: %v", err)
+		}
+	} else {
+		//ron: the layout holds nothing but integers, size first and then
+		//min and max of every rect
+		var values []int
+		for _, match := range ronInteger.FindAllString(string(data), -1) {
+			v, _ := strconv.Atoi(match)
+			values = append(values, v)
+		}
+		if len(values) < 2 || (len(values)-2)%4 != 0 {
+			return Texture{}, fmt.Errorf("bevy layout is not a ron TextureAtlasLayout: expected a size and four numbers per rect, found %v numbers", len(values))
+		}
+		layout.Size = [2]int{values[0], values[1]}
+		for i := 2; i < len(values); i += 4 {
+			layout.Textures = append(layout.Textures, bevyRect{
+				Min: [2]int{values[i], values[i+1]},
+				Max: [2]int{values[i+2], values[i+3]},
+			})
+		}
+	}
+	t := Texture{Size: image.Pt(layout.Size[0], layout.Size[1])}
+	for i, rect := range layout.Textures {
+		t.Regions = append(t.Regions, Region{
+			Name:          fmt.Sprintf("%s%04d", name, i),
+			GeneratedName: true,
+			Rect:          image.Rect(rect.Min[0], rect.Min[1], rect.Max[0], rect.Max[1]),
+		})
+	}
+	return t, nil
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshalling json: %v", err)
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
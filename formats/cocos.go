@@ -0,0 +1,335 @@
+package formats
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteCocosPlist encodes a Texture as a cocos2d-x sprite frame plist in
+// format 2 or 3. Offsets follow cocos' convention: the distance from the
+// center of the source image to the center of the trimmed frame, with y
+// pointing up.
+func WriteCocosPlist(w io.Writer, t Texture, format int) error {
+	if format != 2 && format != 3 {
+		return fmt.Errorf("unsupported cocos plist format %v; only 2 and 3 are supported", format)
+	}
+	p := &plistWriter{w: w}
+	p.line(xml.Header + `<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">`)
+	p.line(`<plist version="1.0">`)
+	p.open("dict")
+	p.key("frames")
+	p.open("dict")
+	for _, region := range t.Regions {
+		source := region.Source()
+		offset := cocosOffset(region)
+		sourceRect := image.Rectangle{Min: region.Offset, Max: region.Offset.Add(region.Rect.Size())}
+		p.key(region.Name)
+		p.open("dict")
+		if format == 2 {
+			p.key("frame")
+			p.value("string", cocosRect(region.Rect))
+			p.key("offset")
+			p.value("string", offset)
+			p.key("rotated")
+			p.bool(region.Rotated)
+			p.key("sourceColorRect")
+			p.value("string", cocosRect(sourceRect))
+			p.key("sourceSize")
+			p.value("string", cocosSize(source))
+		} else {
+			p.key("aliases")
+			p.line("<array/>")
+			p.key("spriteOffset")
+			p.value("string", offset)
+			p.key("spriteSize")
+			p.value("string", cocosSize(region.Rect.Size()))
+			p.key("spriteSourceSize")
+			p.value("string", cocosSize(source))
+			p.key("textureRect")
+			p.value("string", cocosRect(region.Rect))
+			p.key("textureRotated")
+			p.bool(region.Rotated)
+		}
+		p.close("dict")
+	}
+	p.close("dict")
+	p.key("metadata")
+	p.open("dict")
+	p.key("format")
+	p.value("integer", strconv.Itoa(format))
+	if format == 3 {
+		p.key("pixelFormat")
+		p.value("string", "RGBA8888")
+		p.key("premultiplyAlpha")
+		p.bool(false)
+	}
+	p.key("realTextureFileName")
+	p.value("string", path.Base(t.Image))
+	p.key("size")
+	p.value("string", cocosSize(t.Size))
+	p.key("textureFileName")
+	p.value("string", path.Base(t.Image))
+	p.close("dict")
+	p.close("dict")
+	p.line("</plist>")
+	return p.err
+}
+
+// ReadCocosPlist decodes a cocos2d-x sprite frame plist in format 2 or 3.
+func ReadCocosPlist(r io.Reader) (Texture, error) {
+	root, err := decodePlist(xml.NewDecoder(r))
+	if err != nil {
+		return Texture{}, fmt.Errorf("decoding plist: %v", err)
+	}
+	dict, ok := root.(map[string]interface{})
+	if !ok {
+		return Texture{}, fmt.Errorf("plist root is not a dict")
+	}
+	frames, ok := dict["frames"].(map[string]interface{})
+	if !ok {
+		return Texture{}, fmt.Errorf("plist has no frames dict")
+	}
+	var t Texture
+	format := 0
+	if metadata, ok := dict["metadata"].(map[string]interface{}); ok {
+		if f, ok := metadata["format"].(int); ok {
+			format = f
+		}
+		t.Image, _ = metadata["textureFileName"].(string)
+		if size, ok := metadata["size"].(string); ok {
+			t.Size, _ = parseCocosSize(size)
+		}
+	}
+	if format != 2 && format != 3 {
+		return Texture{}, fmt.Errorf("unsupported cocos plist format %v; only 2 and 3 are supported", format)
+	}
+
+	//plist dicts are unordered; keep frames in name order so animations stay together
+	var names []string
+	for name := range frames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		frame, ok := frames[name].(map[string]interface{})
+		if !ok {
+			return Texture{}, fmt.Errorf("frame %v is not a dict", name)
+		}
+		keys := map[string]string{"rect": "frame", "offset": "offset", "source": "sourceSize", "rotated": "rotated"}
+		if format == 3 {
+			keys = map[string]string{"rect": "textureRect", "offset": "spriteOffset", "source": "spriteSourceSize", "rotated": "textureRotated"}
+		}
+		rectString, _ := frame[keys["rect"]].(string)
+		rect, err := parseCocosRect(rectString)
+		if err != nil {
+			return Texture{}, fmt.Errorf("frame %v: %v", name, err)
+		}
+		region := Region{Name: name, Rect: rect}
+		region.Rotated, _ = frame[keys["rotated"]].(bool)
+		if sourceString, ok := frame[keys["source"]].(string); ok {
+			source, err := parseCocosSize(sourceString)
+			if err != nil {
+				return Texture{}, fmt.Errorf("frame %v: %v", name, err)
+			}
+			if source != rect.Size() {
+				region.Trimmed = true
+				region.SourceSize = source
+				offsetString, _ := frame[keys["offset"]].(string)
+				offset, err := parseCocosFloats(offsetString, 2)
+				if err != nil {
+					return Texture{}, fmt.Errorf("frame %v: %v", name, err)
+				}
+				//invert cocosOffset
+				region.Offset = image.Pt(
+					int(math.Round(float64(source.X-rect.Dx())/2+offset[0])),
+					int(math.Round(float64(source.Y-rect.Dy())/2-offset[1])),
+				)
+			}
+		}
+		t.Regions = append(t.Regions, region)
+	}
+	return t, nil
+}
+
+func cocosOffset(region Region) string {
+	source := region.Source()
+	size := region.Rect.Size()
+	x := float64(region.Offset.X) + float64(size.X)/2 - float64(source.X)/2
+	y := float64(source.Y)/2 - (float64(region.Offset.Y) + float64(size.Y)/2)
+	return fmt.Sprintf("{%s,%s}", strconv.FormatFloat(x, 'f', -1, 64), strconv.FormatFloat(y, 'f', -1, 64))
+}
+
+func cocosRect(rect image.Rectangle) string {
+	return fmt.Sprintf("{{%v,%v},{%v,%v}}", rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy())
+}
+
+func cocosSize(size image.Point) string {
+	return fmt.Sprintf("{%v,%v}", size.X, size.Y)
+}
+
+func parseCocosRect(s string) (image.Rectangle, error) {
+	f, err := parseCocosFloats(s, 4)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	x, y := int(f[0]), int(f[1])
+	return image.Rect(x, y, x+int(f[2]), y+int(f[3])), nil
+}
+
+func parseCocosSize(s string) (image.Point, error) {
+	f, err := parseCocosFloats(s, 2)
+	if err != nil {
+		return image.Point{}, err
+	}
+	return image.Pt(int(f[0]), int(f[1])), nil
+}
+
+// parses {a,b} and {{a,b},{c,d}} strings
+func parseCocosFloats(s string, n int) ([]float64, error) {
+	fields := strings.Split(strings.NewReplacer("{", "", "}", "", " ", "").Replace(s), ",")
+	if len(fields) != n {
+		return nil, fmt.Errorf("%q does not have %v values", s, n)
+	}
+	var values []float64
+	for _, field := range fields {
+		f, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %v", s, err)
+		}
+		values = append(values, f)
+	}
+	return values, nil
+}
+
+// writes an indented apple plist one element at a time, remembering the
+// first error
+type plistWriter struct {
+	w      io.Writer
+	indent int
+	err    error
+}
+
+func (p *plistWriter) line(s string) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = fmt.Fprintf(p.w, "%s%s\n", strings.Repeat("    ", p.indent), s)
+}
+
+func (p *plistWriter) open(tag string) {
+	p.line("<" + tag + ">")
+	p.indent++
+}
+
+func (p *plistWriter) close(tag string) {
+	p.indent--
+	p.line("</" + tag + ">")
+}
+
+func (p *plistWriter) key(key string) {
+	p.value("key", key)
+}
+
+func (p *plistWriter) value(tag, value string) {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(value))
+	p.line(fmt.Sprintf("<%s>%s</%s>", tag, escaped.String(), tag))
+}
+
+func (p *plistWriter) bool(b bool) {
+	if b {
+		p.line("<true/>")
+	} else {
+		p.line("<false/>")
+	}
+}
+
+// decodes the next plist value into a map[string]interface{}, []interface{},
+// string, int, float64 or bool
+func decodePlist(d *xml.Decoder) (interface{}, error) {
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local == "plist" {
+			continue
+		}
+		return decodePlistElement(d, start)
+	}
+}
+
+func decodePlistElement(d *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	switch start.Name.Local {
+	case "dict":
+		dict := make(map[string]interface{})
+		for {
+			token, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch el := token.(type) {
+			case xml.EndElement:
+				return dict, nil
+			case xml.StartElement:
+				if el.Name.Local != "key" {
+					return nil, fmt.Errorf("expected <key> in dict, got <%v>", el.Name.Local)
+				}
+				var key string
+				if err := d.DecodeElement(&key, &el); err != nil {
+					return nil, err
+				}
+				value, err := decodePlist(d)
+				if err != nil {
+					return nil, fmt.Errorf("%v: %v", key, err)
+				}
+				dict[key] = value
+			}
+		}
+	case "array":
+		var array []interface{}
+		for {
+			token, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch el := token.(type) {
+			case xml.EndElement:
+				return array, nil
+			case xml.StartElement:
+				value, err := decodePlistElement(d, el)
+				if err != nil {
+					return nil, err
+				}
+				array = append(array, value)
+			}
+		}
+	case "true", "false":
+		if err := d.Skip(); err != nil {
+			return nil, err
+		}
+		return start.Name.Local == "true", nil
+	}
+	var text string
+	if err := d.DecodeElement(&text, &start); err != nil {
+		return nil, err
+	}
+	switch start.Name.Local {
+	case "integer":
+		return strconv.Atoi(strings.TrimSpace(text))
+	case "real":
+		return strconv.ParseFloat(strings.TrimSpace(text), 64)
+	}
+	return text, nil
+}
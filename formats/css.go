@@ -0,0 +1,147 @@
+package formats
+
+import (
+	"fmt"
+	"html/template"
+	"image"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// CSSOptions controls the stylesheet written by WriteCSS.
+type CSSOptions struct {
+	//class shared by every sprite; each sprite also gets <Class>-<name>
+	Class string
+	//adds image-rendering: pixelated so scaled pixel art stays sharp
+	Pixelated bool
+	//adds a rule for high density screens that swaps in <image>@2x, a copy
+	//of the sheet at twice the size, drawn at the sheet's own size
+	Retina bool
+}
+
+// CSSClass returns the class name a region gets in the stylesheet.
+func (o CSSOptions) CSSClass(region Region) string {
+	return o.class() + "-" + cssIdentifier.ReplaceAllString(region.Name, "-")
+}
+
+func (o CSSOptions) class() string {
+	if o.Class == "" {
+		return "sprite"
+	}
+	return o.Class
+}
+
+var cssIdentifier = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// WriteCSS encodes a Texture as a css sprite sheet with one class per region.
+func WriteCSS(w io.Writer, t Texture, opts CSSOptions) error {
+	if opts.Retina && t.Size == (image.Point{}) {
+		return fmt.Errorf("texture size is required to fit the @2x image to the sheet's size")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, ".%s {\n", opts.class())
+	fmt.Fprintf(&b, "  background-image: url(%q);\n", path.Base(t.Image))
+	fmt.Fprintf(&b, "  background-repeat: no-repeat;\n")
+	fmt.Fprintf(&b, "  display: inline-block;\n")
+	if opts.Pixelated {
+		fmt.Fprintf(&b, "  image-rendering: -moz-crisp-edges;\n")
+		fmt.Fprintf(&b, "  image-rendering: pixelated;\n")
+	}
+	fmt.Fprintf(&b, "}\n")
+	//names differing only in characters css identifiers cannot hold, like
+	//a.b and a-b, would share a class and the later rule would win
+	classOf := make(map[string]string)
+	for _, region := range t.Regions {
+		if region.Rotated {
+			return fmt.Errorf("region %v is rotated; css backgrounds cannot be rotated", region.Name)
+		}
+		class := opts.CSSClass(region)
+		if other, ok := classOf[class]; ok {
+			return fmt.Errorf("regions %q and %q would both get css class %v; rename one of them", other, region.Name, class)
+		}
+		classOf[class] = region.Name
+		writeCSSRule(&b, class, region)
+	}
+	if opts.Retina {
+		//sized down to the sheet, the @2x image lines up with every
+		//position and size above
+		fmt.Fprintf(&b, "\n@media (min-resolution: 2dppx), (-webkit-min-device-pixel-ratio: 2) {\n")
+		fmt.Fprintf(&b, "  .%s {\n", opts.class())
+		fmt.Fprintf(&b, "    background-image: url(%q);\n", retinaImage(path.Base(t.Image)))
+		fmt.Fprintf(&b, "    background-size: %s %s;\n", cssPixels(t.Size.X), cssPixels(t.Size.Y))
+		fmt.Fprintf(&b, "  }\n")
+		fmt.Fprintf(&b, "}\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeCSSRule(b *strings.Builder, class string, region Region) {
+	fmt.Fprintf(b, "\n.%s {\n", class)
+	fmt.Fprintf(b, "  background-position: %s %s;\n", cssPixels(-region.Rect.Min.X), cssPixels(-region.Rect.Min.Y))
+	fmt.Fprintf(b, "  width: %s;\n", cssPixels(region.Rect.Dx()))
+	fmt.Fprintf(b, "  height: %s;\n", cssPixels(region.Rect.Dy()))
+	fmt.Fprintf(b, "}\n")
+}
+
+//hero.png becomes hero@2x.png
+func retinaImage(name string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "@2x" + ext
+}
+
+func cssPixels(px int) string {
+	if px == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%vpx", px)
+}
+
+var spriteHTMLTemplate = template.Must(template.New("html").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.Stylesheet}}">
+<style>
+  body { font-family: sans-serif; background: #ccc; }
+  figure { display: inline-block; margin: 8px; padding: 4px; background: #fff; text-align: center; vertical-align: top; }
+  figcaption { font-size: 11px; }
+</style>
+</head>
+<body>
+{{- range .Sprites}}
+<figure>
+  <div class="{{$.Class}} {{.Class}}"></div>
+  <figcaption>{{.Index}}<br>{{.Name}}</figcaption>
+</figure>
+{{- end}}
+</body>
+</html>
+`))
+
+// WriteSpriteHTML writes a preview page rendering every region of the
+// stylesheet written by WriteCSS, labelled with its index and name.
+func WriteSpriteHTML(w io.Writer, t Texture, stylesheet string, opts CSSOptions) error {
+	type sprite struct {
+		Index int
+		Name  string
+		Class string
+	}
+	page := struct {
+		Title      string
+		Stylesheet string
+		Class      string
+		Sprites    []sprite
+	}{
+		Title:      path.Base(t.Image),
+		Stylesheet: stylesheet,
+		Class:      opts.class(),
+	}
+	for i, region := range t.Regions {
+		page.Sprites = append(page.Sprites, sprite{Index: i, Name: region.Name, Class: opts.CSSClass(region)})
+	}
+	return spriteHTMLTemplate.Execute(w, page)
+}
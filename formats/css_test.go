@@ -0,0 +1,31 @@
+package formats
+
+import (
+	"bytes"
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestWriteCSSClassCollision(t *testing.T) {
+	texture := Texture{Image: "hero.png", Regions: []Region{
+		{Name: "walk.s", Rect: image.Rect(0, 0, 8, 8)},
+		{Name: "walk-n", Rect: image.Rect(8, 0, 16, 8)},
+		{Name: "walk-s", Rect: image.Rect(16, 0, 24, 8)},
+	}}
+	err := WriteCSS(&bytes.Buffer{}, texture, CSSOptions{})
+	if err == nil || !strings.Contains(err.Error(), `"walk.s" and "walk-s" would both get css class sprite-walk-s`) {
+		t.Errorf("got %v, want an error naming walk.s and walk-s", err)
+	}
+
+	texture.Regions[2].Name = "walk_s"
+	var buf bytes.Buffer
+	if err := WriteCSS(&buf, texture, CSSOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, class := range []string{".sprite-walk-s {", ".sprite-walk-n {", ".sprite-walk_s {"} {
+		if !strings.Contains(buf.String(), class) {
+			t.Errorf("stylesheet has no %v rule:\n%v", class, buf.String())
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package formats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"image"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CSVOptions controls the table written by WriteCSV.
+type CSVOptions struct {
+	//field separator; ',' for csv and '\t' for tsv
+	Comma rune
+	//adds name and animation columns
+	Names bool
+}
+
+var csvColumns = []string{"index", "id", "x", "y", "w", "h"}
+
+// WriteCSV encodes a Texture as one row per region: its index, an id that
+// stays with the box when reviewers renumber the index column, and the box
+// itself. Reading the table back with ReadCSV gives the same boxes in the
+// same order.
+func WriteCSV(w io.Writer, t Texture, opts CSVOptions) error {
+	out := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		out.Comma = opts.Comma
+	}
+	header := append([]string{}, csvColumns...)
+	if opts.Names {
+		header = append(header, "name", "animation")
+	}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	animations := make(map[int]string)
+	for _, animation := range t.Animations() {
+		for _, frame := range animation.Frames {
+			animations[frame] = animation.Name
+		}
+	}
+	for i, region := range t.Regions {
+		row := []string{
+			strconv.Itoa(i),
+			strconv.Itoa(i),
+			strconv.Itoa(region.Rect.Min.X),
+			strconv.Itoa(region.Rect.Min.Y),
+			strconv.Itoa(region.Rect.Dx()),
+			strconv.Itoa(region.Rect.Dy()),
+		}
+		if opts.Names {
+			row = append(row, region.Name, animations[i])
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// ReadCSV decodes a table written by WriteCSV, possibly after it was
+// edited: columns are found by their header, rows are put back in index
+// order, and rows without a name are named <name><id>, so a box keeps its
+// name when the index column is renumbered (<name><index> without an id
+// column). The animation column is informational and ignored.
+func ReadCSV(r io.Reader, comma rune, name string) (Texture, error) {
+	in := csv.NewReader(r)
+	if comma != 0 {
+		in.Comma = comma
+	}
+	in.TrimLeadingSpace = true
+	records, err := in.ReadAll()
+	if err != nil {
+		return Texture{}, fmt.Errorf("reading table: %v", err)
+	}
+	if len(records) == 0 {
+		return Texture{}, fmt.Errorf("table is empty")
+	}
+	columns := make(map[string]int)
+	for i, column := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	for _, column := range []string{"x", "y", "w", "h"} {
+		if _, ok := columns[column]; !ok {
+			return Texture{}, fmt.Errorf("table has no %q column", column)
+		}
+	}
+
+	type row struct {
+		index  int
+		region Region
+	}
+	var rows []row
+	seen := make(map[int]int)
+	seenIDs := make(map[int]int)
+	for line, record := range records[1:] {
+		line += 2
+		field := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		number := func(column string) (int, error) {
+			n, err := strconv.Atoi(field(column))
+			if err != nil {
+				return 0, fmt.Errorf("line %v: %v is not a number: %q", line, column, field(column))
+			}
+			return n, nil
+		}
+		if strings.Join(record, "") == "" {
+			continue
+		}
+		index := len(rows)
+		if _, ok := columns["index"]; ok {
+			if index, err = number("index"); err != nil {
+				return Texture{}, err
+			}
+		}
+		if previous, ok := seen[index]; ok {
+			return Texture{}, fmt.Errorf("line %v: index %v is already used on line %v", line, index, previous)
+		}
+		seen[index] = line
+		id := index
+		if _, ok := columns["id"]; ok && field("id") != "" {
+			if id, err = number("id"); err != nil {
+				return Texture{}, err
+			}
+			if previous, ok := seenIDs[id]; ok {
+				return Texture{}, fmt.Errorf("line %v: id %v is already used on line %v", line, id, previous)
+			}
+			seenIDs[id] = line
+		}
+		var box [4]int
+		for i, column := range []string{"x", "y", "w", "h"} {
+			if box[i], err = number(column); err != nil {
+				return Texture{}, err
+			}
+		}
+		region := Region{
+			Name: field("name"),
+			Rect: image.Rect(box[0], box[1], box[0]+box[2], box[1]+box[3]),
+		}
+		if region.Name == "" {
+			region.Name = fmt.Sprintf("%s%04d", name, id)
+			region.GeneratedName = true
+		}
+		rows = append(rows, row{index: index, region: region})
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].index < rows[j].index
+	})
+	var t Texture
+	for _, row := range rows {
+		t.Regions = append(t.Regions, row.region)
+	}
+	return t, nil
+}
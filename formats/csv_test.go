@@ -0,0 +1,56 @@
+package formats
+
+import (
+	"bytes"
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestCSVAnimationColumn(t *testing.T) {
+	texture := Texture{Regions: []Region{
+		{Name: "walk.s.0000", Rect: image.Rect(0, 0, 8, 8)},
+		{Name: "walk.s.0001", Rect: image.Rect(8, 0, 16, 8)},
+		{Name: "hero0002", Rect: image.Rect(16, 0, 24, 8), GeneratedName: true},
+	}}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, texture, CSVOptions{Names: true}); err != nil {
+		t.Fatal(err)
+	}
+	want := `index,id,x,y,w,h,name,animation
+0,0,0,0,8,8,walk.s.0000,walk.s
+1,1,8,0,8,8,walk.s.0001,walk.s
+2,2,16,0,8,8,hero0002,
+`
+	if buf.String() != want {
+		t.Errorf("got\n%v\nwant\n%v", buf.String(), want)
+	}
+}
+
+func TestReadCSVNamesByID(t *testing.T) {
+	//a reviewer moved the last box to the front and renumbered the index
+	table := `index,id,x,y,w,h
+0,2,16,0,8,8
+1,0,0,0,8,8
+2,1,8,0,8,8
+`
+	texture, err := ReadCSV(strings.NewReader(table), ',', "hero")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, region := range texture.Regions {
+		names = append(names, region.Name)
+	}
+	if got, want := strings.Join(names, " "), "hero0002 hero0000 hero0001"; got != want {
+		t.Errorf("names %v, want %v", got, want)
+	}
+	if texture.Regions[0].Rect != image.Rect(16, 0, 24, 8) {
+		t.Errorf("first region %v, want the box that had id 2", texture.Regions[0].Rect)
+	}
+
+	_, err = ReadCSV(strings.NewReader("index,id,x,y,w,h\n0,1,0,0,8,8\n1,1,8,0,8,8\n"), ',', "hero")
+	if err == nil || !strings.Contains(err.Error(), "id 1 is already used") {
+		t.Errorf("expected a duplicate id error, got %v", err)
+	}
+}
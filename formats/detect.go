@@ -0,0 +1,67 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Detect names the format of a box or atlas file from its content: boxes,
+// atlas, unity, starling, cocos, tiled, bevy, bevy-json, csv or tsv.
+func Detect(data []byte) (string, error) {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if len(trimmed) == 0 {
+		return "", fmt.Errorf("file is empty")
+	}
+	switch trimmed[0] {
+	case '{':
+		var keys map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &keys); err != nil {
+			return "", fmt.Errorf("file looks like json but does not parse: %v", err)
+		}
+		switch {
+		case keys["sprites"] != nil:
+			return "boxes", nil
+		case keys["frames"] != nil:
+			return "atlas", nil
+		case keys["textures"] != nil && keys["size"] != nil:
+			return "bevy-json", nil
+		}
+		return "", fmt.Errorf("json has none of the keys sprites, frames or textures")
+	case '<':
+		switch {
+		case bytes.Contains(trimmed, []byte("<TextureAtlas")):
+			return "starling", nil
+		case bytes.Contains(trimmed, []byte("<plist")):
+			return "cocos", nil
+		case bytes.Contains(trimmed, []byte("<tileset")):
+			return "tiled", nil
+		}
+		return "", fmt.Errorf("xml is not a TextureAtlas, plist or tileset")
+	case '(':
+		if bytes.Contains(trimmed, []byte("textures")) {
+			return "bevy", nil
+		}
+		return "", fmt.Errorf("ron is not a TextureAtlasLayout")
+	}
+	if bytes.Contains(trimmed, []byte("TextureImporter:")) {
+		return "unity", nil
+	}
+
+	//tables start with a header naming the box columns
+	header := strings.ToLower(strings.SplitN(string(trimmed), "\n", 2)[0])
+	for _, comma := range []string{"\t", ","} {
+		columns := make(map[string]bool)
+		for _, column := range strings.Split(header, comma) {
+			columns[strings.TrimSpace(column)] = true
+		}
+		if columns["x"] && columns["y"] && columns["w"] && columns["h"] {
+			if comma == "\t" {
+				return "tsv", nil
+			}
+			return "csv", nil
+		}
+	}
+	return "", fmt.Errorf("unrecognized format")
+}
@@ -0,0 +1,82 @@
+package formats
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Features lists the region attributes, beyond a box, that a format can
+// store. Converting to a format without one of them loses that attribute.
+type Features struct {
+	Names    bool
+	Pivots   bool
+	Rotation bool
+	Trimming bool
+	Borders  bool
+	//regions drawn mirrored left to right
+	Flips bool
+}
+
+// Dropped describes every attribute used by t that a format with the given
+// features cannot store, e.g. "pivots (12 of 40 regions)".
+func Dropped(t Texture, keeps Features) []string {
+	var named, pivots, rotated, trimmed, borders, flipped int
+	for _, region := range t.Regions {
+		//made up names are not worth keeping
+		if region.Name != "" && !region.GeneratedName {
+			named++
+		}
+		if region.Pivot != nil {
+			pivots++
+		}
+		if region.Rotated {
+			rotated++
+		}
+		if region.Trimmed {
+			trimmed++
+		}
+		if region.Border != (Border{}) {
+			borders++
+		}
+		if region.FlipX {
+			flipped++
+		}
+	}
+	var dropped []string
+	drop := func(kept bool, count int, what string) {
+		if !kept && count > 0 {
+			dropped = append(dropped, fmt.Sprintf("%s (%v of %v regions)", what, count, len(t.Regions)))
+		}
+	}
+	drop(keeps.Names, named, "names")
+	drop(keeps.Pivots, pivots, "pivots")
+	drop(keeps.Rotation, rotated, "rotation")
+	drop(keeps.Trimming, trimmed, "trimming")
+	drop(keeps.Borders, borders, "borders")
+	drop(keeps.Flips, flipped, "horizontal flips")
+	return dropped
+}
+
+// String lists the features that are set.
+func (f Features) String() string {
+	var kept []string
+	for _, feature := range []struct {
+		kept bool
+		name string
+	}{
+		{f.Names, "names"},
+		{f.Pivots, "pivots"},
+		{f.Rotation, "rotation"},
+		{f.Trimming, "trimming"},
+		{f.Borders, "borders"},
+		{f.Flips, "horizontal flips"},
+	} {
+		if feature.kept {
+			kept = append(kept, feature.name)
+		}
+	}
+	if len(kept) == 0 {
+		return "boxes only"
+	}
+	return strings.Join(kept, ", ")
+}
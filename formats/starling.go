@@ -0,0 +1,106 @@
+package formats
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"io"
+)
+
+// the TextureAtlas xml shared by starling, sparrow, haxeflixel and
+// monogame.extended
+type starlingAtlas struct {
+	XMLName     xml.Name             `xml:"TextureAtlas"`
+	ImagePath   string               `xml:"imagePath,attr"`
+	SubTextures []starlingSubTexture `xml:"SubTexture"`
+}
+
+type starlingSubTexture struct {
+	Name        string   `xml:"name,attr"`
+	X           int      `xml:"x,attr"`
+	Y           int      `xml:"y,attr"`
+	Width       int      `xml:"width,attr"`
+	Height      int      `xml:"height,attr"`
+	FrameX      *int     `xml:"frameX,attr"`
+	FrameY      *int     `xml:"frameY,attr"`
+	FrameWidth  *int     `xml:"frameWidth,attr"`
+	FrameHeight *int     `xml:"frameHeight,attr"`
+	PivotX      *float64 `xml:"pivotX,attr"`
+	PivotY      *float64 `xml:"pivotY,attr"`
+	Rotated     bool     `xml:"rotated,attr,omitempty"`
+}
+
+// WriteStarlingXML encodes a Texture as a starling/sparrow TextureAtlas.
+// Frame numbers are padded to four digits so that prefix lookups such as
+// getTextures("attack.s.") return frames in order.
+func WriteStarlingXML(w io.Writer, t Texture) error {
+	atlas := starlingAtlas{ImagePath: t.Image}
+	for _, region := range t.Regions {
+		sub := starlingSubTexture{
+			Name:    PaddedFrameName(region.Name),
+			X:       region.Rect.Min.X,
+			Y:       region.Rect.Min.Y,
+			Width:   region.Rect.Dx(),
+			Height:  region.Rect.Dy(),
+			Rotated: region.Rotated,
+		}
+		if region.Trimmed {
+			//frameX/Y place the frame relative to the trimmed pixels, so they are negative
+			source := region.Source()
+			frameX, frameY := -region.Offset.X, -region.Offset.Y
+			sub.FrameX, sub.FrameY = &frameX, &frameY
+			sub.FrameWidth, sub.FrameHeight = &source.X, &source.Y
+		}
+		if region.Pivot != nil {
+			//starling pivots are in pixels, relative to the untrimmed frame
+			source := region.Source()
+			pivotX := region.Pivot.X * float64(source.X)
+			pivotY := region.Pivot.Y * float64(source.Y)
+			sub.PivotX, sub.PivotY = &pivotX, &pivotY
+		}
+		atlas.SubTextures = append(atlas.SubTextures, sub)
+	}
+	data, err := xml.MarshalIndent(atlas, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling texture atlas: %v", err)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// ReadStarlingXML decodes a starling/sparrow TextureAtlas.
+func ReadStarlingXML(r io.Reader) (Texture, error) {
+	var atlas starlingAtlas
+	if err := xml.NewDecoder(r).Decode(&atlas); err != nil {
+		return Texture{}, fmt.Errorf("decoding texture atlas: %v", err)
+	}
+	t := Texture{Image: atlas.ImagePath}
+	for _, sub := range atlas.SubTextures {
+		region := Region{
+			Name:    sub.Name,
+			Rect:    image.Rect(sub.X, sub.Y, sub.X+sub.Width, sub.Y+sub.Height),
+			Rotated: sub.Rotated,
+		}
+		if sub.FrameWidth != nil && sub.FrameHeight != nil {
+			region.Trimmed = true
+			region.SourceSize = image.Pt(*sub.FrameWidth, *sub.FrameHeight)
+			if sub.FrameX != nil && sub.FrameY != nil {
+				region.Offset = image.Pt(-*sub.FrameX, -*sub.FrameY)
+			}
+		}
+		if sub.PivotX != nil && sub.PivotY != nil {
+			source := region.Source()
+			if source.X > 0 && source.Y > 0 {
+				region.Pivot = &Pivot{
+					X: *sub.PivotX / float64(source.X),
+					Y: *sub.PivotY / float64(source.Y),
+				}
+			}
+		}
+		t.Regions = append(t.Regions, region)
+	}
+	return t, nil
+}
@@ -0,0 +1,181 @@
+package formats
+
+import (
+	"fmt"
+	"image"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ilackarms/sprite-locator/models"
+)
+
+// Texture is the format-neutral description of a sprite sheet that every
+// reader in this package produces and every writer consumes.
+type Texture struct {
+	//path of the sheet image, as it should be referenced by the output
+	Image string
+	//pixel size of the sheet image; needed by formats with a bottom-left origin
+	Size    image.Point
+	Regions []Region
+}
+
+// Region is a single named rectangle of a Texture.
+type Region struct {
+	Name string
+	//position and unrotated size of the region inside the texture
+	Rect    image.Rectangle
+	Rotated bool
+	Trimmed bool
+	//where Rect sits inside the untrimmed source image
+	Offset image.Point
+	//untrimmed size; zero means the same as Rect.Size()
+	SourceSize image.Point
+	//nil when the source format did not define a pivot
+	Pivot *Pivot
+	//Pivot is the center of a pixel rather than a line between pixels; only
+	//locator boxes and the atlas record it
+	PivotOnPixel bool
+	Border       Border
+	//Rect is drawn mirrored left to right, showing the region named MirrorOf
+	FlipX    bool
+	MirrorOf string
+	//Name was made up by the reader, e.g. hero0003, because the input did
+	//not name the region; such names say nothing about animations
+	GeneratedName bool
+}
+
+// Pivot is a normalized anchor point measured from the top-left corner of
+// the untrimmed sprite; {0.5, 0.5} is the center.
+type Pivot struct {
+	X float64
+	Y float64
+}
+
+// Border is the 9-slice inset of a region, in pixels.
+type Border struct {
+	Left   int
+	Top    int
+	Right  int
+	Bottom int
+}
+
+var CenterPivot = Pivot{X: 0.5, Y: 0.5}
+
+// Source returns the size of the untrimmed sprite.
+func (r Region) Source() image.Point {
+	if r.SourceSize == (image.Point{}) {
+		return r.Rect.Size()
+	}
+	return r.SourceSize
+}
+
+// PivotOrCenter returns the region's pivot, defaulting to its center.
+func (r Region) PivotOrCenter() Pivot {
+	if r.Pivot == nil {
+		return CenterPivot
+	}
+	return *r.Pivot
+}
+
+// FromSpritesheet converts locator output into a Texture. Regions are
+// named <name>0000, <name>0001, ... in sheet order.
+func FromSpritesheet(name string, sheet models.Spritesheet) Texture {
+	var t Texture
+	for i, sprite := range sheet.Sprites {
+		t.Regions = append(t.Regions, Region{
+			Name:          fmt.Sprintf("%s%04d", name, i),
+			GeneratedName: true,
+			Rect:          sprite.Rect(),
+			Pivot:         pivotOf(sprite.Pivot),
+			PivotOnPixel:  sprite.PivotOnPixel,
+		})
+	}
+	return t
+}
+
+// Spritesheet converts a Texture back into locator boxes, dropping names
+// and every other per-region attribute but pivots.
+func (t Texture) Spritesheet() models.Spritesheet {
+	var sheet models.Spritesheet
+	for _, region := range t.Regions {
+		//locator boxes name the lower right pixel itself as Max
+		sprite := models.Sprite{
+			Min: models.Point{X: region.Rect.Min.X, Y: region.Rect.Min.Y},
+			Max: models.Point{X: region.Rect.Max.X - 1, Y: region.Rect.Max.Y - 1},
+		}
+		if region.Pivot != nil {
+			sprite.Pivot = &models.Pivot{X: region.Pivot.X, Y: region.Pivot.Y}
+			sprite.PivotPixel = pivotPixel(*region.Pivot, sprite.Size(), region.PivotOnPixel)
+			sprite.PivotOnPixel = region.PivotOnPixel
+		}
+		sheet.Sprites = append(sheet.Sprites, sprite)
+	}
+	return sheet
+}
+
+// Animation is a sequence of regions sharing a frame name prefix.
+type Animation struct {
+	Name string
+	//indexes into Texture.Regions, in frame order
+	Frames []int
+}
+
+var frameNumber = regexp.MustCompile(`^(.*?)(\d+)$`)
+
+// SplitFrameName splits a frame name such as attack.s.0003 or Attack.S0003
+// into its animation prefix (attack.s. / Attack.S) and frame number.
+func SplitFrameName(name string) (string, int, bool) {
+	match := frameNumber.FindStringSubmatch(name)
+	if match == nil || match[1] == "" {
+		return name, 0, false
+	}
+	n, err := strconv.Atoi(match[2])
+	if err != nil {
+		return name, 0, false
+	}
+	return match[1], n, true
+}
+
+// PaddedFrameName rewrites a frame name so its number has at least four
+// digits, which keeps alphabetical and frame order the same for engines
+// that look animations up by prefix.
+func PaddedFrameName(name string) string {
+	prefix, n, ok := SplitFrameName(name)
+	if !ok {
+		return name
+	}
+	return fmt.Sprintf("%s%04d", prefix, n)
+}
+
+// Animations groups regions into animations by frame name prefix, in the
+// order each animation first appears. Regions without a frame number or
+// with a generated name are left out.
+func (t Texture) Animations() []Animation {
+	var animations []Animation
+	byName := make(map[string]int)
+	numbers := make(map[int]int)
+	for i, region := range t.Regions {
+		prefix, n, ok := SplitFrameName(region.Name)
+		if !ok || region.GeneratedName {
+			continue
+		}
+		name := strings.TrimRight(prefix, "._-")
+		index, ok := byName[name]
+		if !ok {
+			index = len(animations)
+			byName[name] = index
+			animations = append(animations, Animation{Name: name})
+		}
+		animations[index].Frames = append(animations[index].Frames, i)
+		numbers[i] = n
+	}
+	for _, animation := range animations {
+		frames := animation.Frames
+		sort.SliceStable(frames, func(a, b int) bool {
+			return numbers[frames[a]] < numbers[frames[b]]
+		})
+	}
+	return animations
+}
@@ -0,0 +1,96 @@
+package formats
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+
+	"github.com/ilackarms/sprite-locator/algorithm"
+	"github.com/ilackarms/sprite-locator/models"
+)
+
+//an 8x8 sprite at (2,2) on a 20x20 sheet, located the way locate does it
+func locatedSheet(t *testing.T) models.Spritesheet {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(2, 2, 10, 10), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+	found := (&algorithm.FloodFillAlgorithm{Margin: 1}).FindSprites(img)
+	if len(found) != 1 {
+		t.Fatalf("located %v sprites, want 1", len(found))
+	}
+	sprite := models.Sprite{
+		Min: models.Point{X: found[0].Min.X, Y: found[0].Min.Y},
+		Max: models.Point{X: found[0].Max.X, Y: found[0].Max.Y},
+	}
+	if sprite.Max != (models.Point{X: 9, Y: 9}) {
+		t.Fatalf("located Max %v, want the lower right pixel (9,9)", sprite.Max)
+	}
+	return models.Spritesheet{Sprites: []models.Sprite{sprite}}
+}
+
+func TestFromSpritesheetIncludesMax(t *testing.T) {
+	sheet := locatedSheet(t)
+	texture := FromSpritesheet("hero", sheet)
+	if got, want := texture.Regions[0].Rect, image.Rect(2, 2, 10, 10); got != want {
+		t.Errorf("region rect %v, want %v", got, want)
+	}
+	if got := texture.Spritesheet().Sprites[0]; got.Min != sheet.Sprites[0].Min || got.Max != sheet.Sprites[0].Max {
+		t.Errorf("boxes read back as %v-%v, want %v-%v", got.Min, got.Max, sheet.Sprites[0].Min, sheet.Sprites[0].Max)
+	}
+
+	texture.Size = image.Pt(20, 20)
+	var buf bytes.Buffer
+	if err := WriteUnityMeta(&buf, texture); err != nil {
+		t.Fatal(err)
+	}
+	//20 - 10: the rect's bottom edge is below pixel row 9
+	want := "x: 2\n        y: 10\n        width: 8\n        height: 8\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("unity rect is not the 8x8 sprite:\n%v", buf.String())
+	}
+}
+
+func TestGeneratedNamesHaveNoAnimations(t *testing.T) {
+	sheet := locatedSheet(t)
+	sheet.Sprites = append(sheet.Sprites, sheet.Sprites[0], sheet.Sprites[0])
+	texture := FromSpritesheet("hero", sheet)
+	texture.Image, texture.Size = "hero.png", image.Pt(20, 20)
+	if animations := texture.Animations(); len(animations) != 0 {
+		t.Errorf("hero0000, hero0001, ... made up animations %+v", animations)
+	}
+	var buf bytes.Buffer
+	if err := WriteTiledTileset(&buf, texture, TiledOptions{FrameDuration: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "<animation>") {
+		t.Errorf("tileset animates sprites that are not frames of an animation:\n%v", buf.String())
+	}
+
+	texture.Regions[0].GeneratedName = false
+	texture.Regions[1].GeneratedName = false
+	if animations := texture.Animations(); len(animations) != 1 || len(animations[0].Frames) != 2 {
+		t.Errorf("named frames grouped as %+v, want one animation of 2 frames", animations)
+	}
+}
+
+func TestPixelPivotSurvivesAtlas(t *testing.T) {
+	sheet := locatedSheet(t)
+	//the last pixel column is at 7/8 + 1/16 of the sprite, nearer the
+	//right edge than its own left side
+	sheet.Sprites[0].SetPivot(image.Pt(7, 4), true)
+	var buf bytes.Buffer
+	if err := WriteAtlas(&buf, FromSpritesheet("hero", sheet)); err != nil {
+		t.Fatal(err)
+	}
+	texture, err := ReadAtlas(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sprite := texture.Spritesheet().Sprites[0]
+	if !sprite.PivotOnPixel || *sprite.PivotPixel != (models.Point{X: 7, Y: 4}) {
+		t.Errorf("pivot read back as %+v on pixel %v, want pixel (7,4)", *sprite.PivotPixel, sprite.PivotOnPixel)
+	}
+}
@@ -0,0 +1,329 @@
+package formats
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"io"
+	"path"
+	"sort"
+)
+
+// TiledOptions controls the tileset written by WriteTiledTileset.
+type TiledOptions struct {
+	Name string
+	//milliseconds each animation frame is shown for
+	FrameDuration int
+}
+
+type tiledTileset struct {
+	XMLName      xml.Name    `xml:"tileset"`
+	Version      string      `xml:"version,attr"`
+	TiledVersion string      `xml:"tiledversion,attr"`
+	Name         string      `xml:"name,attr"`
+	TileWidth    int         `xml:"tilewidth,attr"`
+	TileHeight   int         `xml:"tileheight,attr"`
+	Spacing      int         `xml:"spacing,attr,omitempty"`
+	Margin       int         `xml:"margin,attr,omitempty"`
+	TileCount    int         `xml:"tilecount,attr"`
+	Columns      int         `xml:"columns,attr"`
+	Grid         *tiledGrid  `xml:"grid"`
+	Image        *tiledImage `xml:"image"`
+	Tiles        []tiledTile `xml:"tile"`
+}
+
+type tiledGrid struct {
+	Orientation string `xml:"orientation,attr"`
+	Width       int    `xml:"width,attr"`
+	Height      int    `xml:"height,attr"`
+}
+
+type tiledImage struct {
+	Source string `xml:"source,attr"`
+	Width  int    `xml:"width,attr"`
+	Height int    `xml:"height,attr"`
+}
+
+type tiledTile struct {
+	ID         int              `xml:"id,attr"`
+	X          *int             `xml:"x,attr"`
+	Y          *int             `xml:"y,attr"`
+	Width      *int             `xml:"width,attr"`
+	Height     *int             `xml:"height,attr"`
+	Properties *tiledProperties `xml:"properties"`
+	Image      *tiledImage      `xml:"image"`
+	Animation  *tiledAnimation  `xml:"animation"`
+}
+
+type tiledProperties struct {
+	Properties []tiledProperty `xml:"property"`
+}
+
+type tiledProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type tiledAnimation struct {
+	Frames []tiledFrame `xml:"frame"`
+}
+
+type tiledFrame struct {
+	TileID   int `xml:"tileid,attr"`
+	Duration int `xml:"duration,attr"`
+}
+
+// tiledGridLayout describes regions that all share a size and sit on a
+// regular lattice, which tiled can load as an ordinary tileset.
+type tiledGridLayout struct {
+	tileSize image.Point
+	margin   int
+	spacing  int
+	columns  int
+	rows     int
+}
+
+// WriteTiledTileset encodes a Texture as a tiled .tsx tileset. Regions cut
+// from a uniform grid become a regular tileset; anything else becomes a
+// tileset of sub-rectangles of the sheet image. Animations found in the
+// frame names are attached to the first tile of each animation.
+func WriteTiledTileset(w io.Writer, t Texture, opts TiledOptions) error {
+	if t.Size == (image.Point{}) {
+		return fmt.Errorf("texture size is required to write a tiled tileset")
+	}
+	if opts.FrameDuration <= 0 {
+		opts.FrameDuration = 100
+	}
+	for _, region := range t.Regions {
+		if region.Rotated {
+			return fmt.Errorf("region %v is rotated; tiled tiles cannot be rotated", region.Name)
+		}
+	}
+	tileset := tiledTileset{
+		Version:      "1.10",
+		TiledVersion: "1.10.2",
+		Name:         opts.Name,
+	}
+	if tileset.Name == "" {
+		tileset.Name = path.Base(t.Image)
+	}
+
+	//tile id of each region
+	ids := make([]int, len(t.Regions))
+	tiles := make(map[int]*tiledTile)
+	tile := func(id int) *tiledTile {
+		if tiles[id] == nil {
+			tiles[id] = &tiledTile{ID: id}
+		}
+		return tiles[id]
+	}
+
+	if grid, ok := findTiledGrid(t); ok {
+		tileset.TileWidth, tileset.TileHeight = grid.tileSize.X, grid.tileSize.Y
+		tileset.Margin, tileset.Spacing = grid.margin, grid.spacing
+		tileset.Columns = grid.columns
+		tileset.TileCount = grid.columns * grid.rows
+		tileset.Image = &tiledImage{Source: t.Image, Width: t.Size.X, Height: t.Size.Y}
+		step := grid.tileSize.Add(image.Pt(grid.spacing, grid.spacing))
+		for i, region := range t.Regions {
+			col := (region.Rect.Min.X - grid.margin) / step.X
+			row := (region.Rect.Min.Y - grid.margin) / step.Y
+			ids[i] = row*grid.columns + col
+			tile(ids[i]).Properties = tiledName(region)
+		}
+	} else {
+		tileset.TileCount = len(t.Regions)
+		tileset.Grid = &tiledGrid{Orientation: "orthogonal", Width: 1, Height: 1}
+		for i, region := range t.Regions {
+			size := region.Rect.Size()
+			if size.X > tileset.TileWidth {
+				tileset.TileWidth = size.X
+			}
+			if size.Y > tileset.TileHeight {
+				tileset.TileHeight = size.Y
+			}
+			x, y := region.Rect.Min.X, region.Rect.Min.Y
+			ids[i] = i
+			tile := tile(i)
+			tile.X, tile.Y, tile.Width, tile.Height = &x, &y, &size.X, &size.Y
+			tile.Properties = tiledName(region)
+			tile.Image = &tiledImage{Source: t.Image, Width: t.Size.X, Height: t.Size.Y}
+		}
+	}
+
+	for _, animation := range t.Animations() {
+		var frames []tiledFrame
+		for _, frame := range animation.Frames {
+			frames = append(frames, tiledFrame{TileID: ids[frame], Duration: opts.FrameDuration})
+		}
+		tile(ids[animation.Frames[0]]).Animation = &tiledAnimation{Frames: frames}
+	}
+
+	var tileIDs []int
+	for id := range tiles {
+		tileIDs = append(tileIDs, id)
+	}
+	sort.Ints(tileIDs)
+	for _, id := range tileIDs {
+		tileset.Tiles = append(tileset.Tiles, *tiles[id])
+	}
+
+	data, err := xml.MarshalIndent(tileset, "", " ")
+	if err != nil {
+		return fmt.Errorf("marshalling tileset: %v", err)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// ReadTiledTileset decodes a tiled .tsx tileset, either a regular grid
+// tileset or one built from sub-rectangles of a single image.
+func ReadTiledTileset(r io.Reader) (Texture, error) {
+	var tileset tiledTileset
+	if err := xml.NewDecoder(r).Decode(&tileset); err != nil {
+		return Texture{}, fmt.Errorf("decoding tileset: %v", err)
+	}
+	names := make(map[int]string)
+	for _, tile := range tileset.Tiles {
+		if tile.Properties == nil {
+			continue
+		}
+		for _, property := range tile.Properties.Properties {
+			if property.Name == "name" {
+				names[tile.ID] = property.Value
+			}
+		}
+	}
+	region := func(id int, rect image.Rectangle) Region {
+		if n, ok := names[id]; ok {
+			return Region{Name: n, Rect: rect}
+		}
+		return Region{Name: fmt.Sprintf("%s%04d", tileset.Name, id), Rect: rect, GeneratedName: true}
+	}
+
+	var t Texture
+	if tileset.Image != nil {
+		t.Image = tileset.Image.Source
+		t.Size = image.Pt(tileset.Image.Width, tileset.Image.Height)
+		if tileset.Columns <= 0 {
+			return Texture{}, fmt.Errorf("tileset %v has an image but no columns", tileset.Name)
+		}
+		for id := 0; id < tileset.TileCount; id++ {
+			col, row := id%tileset.Columns, id/tileset.Columns
+			x := tileset.Margin + col*(tileset.TileWidth+tileset.Spacing)
+			y := tileset.Margin + row*(tileset.TileHeight+tileset.Spacing)
+			t.Regions = append(t.Regions, region(id, image.Rect(x, y, x+tileset.TileWidth, y+tileset.TileHeight)))
+		}
+		return t, nil
+	}
+	for _, tile := range tileset.Tiles {
+		if tile.Image == nil {
+			continue
+		}
+		if t.Image == "" {
+			t.Image = tile.Image.Source
+			t.Size = image.Pt(tile.Image.Width, tile.Image.Height)
+		} else if tile.Image.Source != t.Image {
+			return Texture{}, fmt.Errorf("tile %v uses image %v; only tilesets cut from a single image are supported", tile.ID, tile.Image.Source)
+		}
+		rect := image.Rect(0, 0, tile.Image.Width, tile.Image.Height)
+		if tile.X != nil && tile.Y != nil && tile.Width != nil && tile.Height != nil {
+			rect = image.Rect(*tile.X, *tile.Y, *tile.X+*tile.Width, *tile.Y+*tile.Height)
+		}
+		t.Regions = append(t.Regions, region(tile.ID, rect))
+	}
+	return t, nil
+}
+
+func tiledName(region Region) *tiledProperties {
+	return &tiledProperties{Properties: []tiledProperty{{Name: "name", Value: region.Name}}}
+}
+
+// findTiledGrid reports whether every region has the same size and lies on
+// one lattice of cells separated by a constant spacing.
+func findTiledGrid(t Texture) (tiledGridLayout, bool) {
+	if len(t.Regions) == 0 {
+		return tiledGridLayout{}, false
+	}
+	size := t.Regions[0].Rect.Size()
+	if size.X <= 0 || size.Y <= 0 {
+		return tiledGridLayout{}, false
+	}
+	xs := make(map[int]bool)
+	ys := make(map[int]bool)
+	for _, region := range t.Regions {
+		if region.Rect.Size() != size {
+			return tiledGridLayout{}, false
+		}
+		xs[region.Rect.Min.X] = true
+		ys[region.Rect.Min.Y] = true
+	}
+	marginX, spacingX, ok := latticeOf(xs, size.X)
+	if !ok {
+		return tiledGridLayout{}, false
+	}
+	marginY, spacingY, ok := latticeOf(ys, size.Y)
+	if !ok {
+		return tiledGridLayout{}, false
+	}
+	//tiled has a single margin and spacing for both axes
+	if len(xs) > 1 && len(ys) > 1 && spacingX != spacingY {
+		return tiledGridLayout{}, false
+	}
+	spacing := spacingX
+	if len(xs) == 1 {
+		spacing = spacingY
+	}
+	if marginX != marginY {
+		return tiledGridLayout{}, false
+	}
+	grid := tiledGridLayout{tileSize: size, margin: marginX, spacing: spacing}
+	grid.columns = (t.Size.X - 2*grid.margin + spacing) / (size.X + spacing)
+	grid.rows = (t.Size.Y - 2*grid.margin + spacing) / (size.Y + spacing)
+	for _, region := range t.Regions {
+		if !region.Rect.In(image.Rect(0, 0, t.Size.X, t.Size.Y)) {
+			return tiledGridLayout{}, false
+		}
+		if (region.Rect.Min.X-grid.margin)/(size.X+spacing) >= grid.columns ||
+			(region.Rect.Min.Y-grid.margin)/(size.Y+spacing) >= grid.rows {
+			return tiledGridLayout{}, false
+		}
+	}
+	return grid, true
+}
+
+// latticeOf finds the offset of the first cell and the gap between cells
+// along one axis, given the cell starts seen on that axis.
+func latticeOf(starts map[int]bool, cell int) (int, int, bool) {
+	var sorted []int
+	for start := range starts {
+		sorted = append(sorted, start)
+	}
+	sort.Ints(sorted)
+	first := sorted[0]
+	if len(sorted) == 1 {
+		return first, 0, true
+	}
+	//the smallest step between cells; every other start must be a multiple of it
+	step := sorted[1] - sorted[0]
+	for i := 2; i < len(sorted); i++ {
+		if d := sorted[i] - sorted[i-1]; d < step {
+			step = d
+		}
+	}
+	if step < cell {
+		return 0, 0, false
+	}
+	for _, start := range sorted {
+		if (start-first)%step != 0 {
+			return 0, 0, false
+		}
+	}
+	//cells may not start at the first lattice point; tiled needs the margin to
+	//be the offset of column 0, so it has to fit before the first cell
+	margin := first % step
+	return margin, step - cell, true
+}
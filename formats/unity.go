@@ -0,0 +1,256 @@
+package formats
+
+import (
+	"crypto/md5"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+// unity's SpriteAlignment enum, indexed by value; custom (9) is not listed
+var unityAlignments = []Pivot{
+	{X: 0.5, Y: 0.5}, //center
+	{X: 0, Y: 1},     //top left
+	{X: 0.5, Y: 1},   //top center
+	{X: 1, Y: 1},     //top right
+	{X: 0, Y: 0.5},   //left center
+	{X: 1, Y: 0.5},   //right center
+	{X: 0, Y: 0},     //bottom left
+	{X: 0.5, Y: 0},   //bottom center
+	{X: 1, Y: 0},     //bottom right
+}
+
+const (
+	unityCustomAlignment    = 9
+	unitySpriteModeMultiple = 2
+	unityTextureTypeSprite  = 8
+)
+
+type unityMeta struct {
+	FileFormatVersion int                  `yaml:"fileFormatVersion"`
+	GUID              string               `yaml:"guid"`
+	TextureImporter   unityTextureImporter `yaml:"TextureImporter"`
+}
+
+type unityTextureImporter struct {
+	SerializedVersion   int              `yaml:"serializedVersion"`
+	TextureType         int              `yaml:"textureType"`
+	SpriteMode          int              `yaml:"spriteMode"`
+	SpritePixelsToUnits int              `yaml:"spritePixelsToUnits"`
+	SpritePivot         unityVector2     `yaml:"spritePivot,flow"`
+	Alignment           int              `yaml:"alignment"`
+	SpriteSheet         unitySpriteSheet `yaml:"spriteSheet"`
+}
+
+type unitySpriteSheet struct {
+	SerializedVersion int           `yaml:"serializedVersion"`
+	Sprites           []unitySprite `yaml:"sprites"`
+}
+
+type unitySprite struct {
+	SerializedVersion int          `yaml:"serializedVersion"`
+	Name              string       `yaml:"name"`
+	Rect              unityRect    `yaml:"rect"`
+	Alignment         int          `yaml:"alignment"`
+	Pivot             unityVector2 `yaml:"pivot,flow"`
+	Border            unityVector4 `yaml:"border,flow"`
+	SpriteID          string       `yaml:"spriteID"`
+}
+
+type unityRect struct {
+	SerializedVersion int `yaml:"serializedVersion"`
+	X                 int `yaml:"x"`
+	Y                 int `yaml:"y"`
+	Width             int `yaml:"width"`
+	Height            int `yaml:"height"`
+}
+
+type unityVector2 struct {
+	X float64 `yaml:"x"`
+	Y float64 `yaml:"y"`
+}
+
+//unity writes plain decimals; %v would print a rounded 1e-6 as 1e-06
+var unityMetaFuncs = template.FuncMap{
+	"float": func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	},
+}
+
+var unityMetaTemplate = template.Must(template.New("meta").Funcs(unityMetaFuncs).Parse(`fileFormatVersion: {{.FileFormatVersion}}
+guid: {{.GUID}}
+TextureImporter:
+{{- with .TextureImporter}}
+  serializedVersion: {{.SerializedVersion}}
+  textureType: {{.TextureType}}
+  spriteMode: {{.SpriteMode}}
+  spritePixelsToUnits: {{.SpritePixelsToUnits}}
+  spritePivot: {x: {{float .SpritePivot.X}}, y: {{float .SpritePivot.Y}}}
+  alignment: {{.Alignment}}
+  spriteSheet:
+    serializedVersion: {{.SpriteSheet.SerializedVersion}}
+    sprites:
+{{- range .SpriteSheet.Sprites}}
+    - serializedVersion: {{.SerializedVersion}}
+      name: {{printf "%q" .Name}}
+      rect:
+        serializedVersion: {{.Rect.SerializedVersion}}
+        x: {{.Rect.X}}
+        y: {{.Rect.Y}}
+        width: {{.Rect.Width}}
+        height: {{.Rect.Height}}
+      alignment: {{.Alignment}}
+      pivot: {x: {{float .Pivot.X}}, y: {{float .Pivot.Y}}}
+      border: {x: {{.Border.X}}, y: {{.Border.Y}}, z: {{.Border.Z}}, w: {{.Border.W}}}
+      spriteID: {{.SpriteID}}
+{{- end}}
+{{- end}}
+`))
+
+// border is left, bottom, right, top
+type unityVector4 struct {
+	X int `yaml:"x"`
+	Y int `yaml:"y"`
+	Z int `yaml:"z"`
+	W int `yaml:"w"`
+}
+
+// WriteUnityMeta encodes a Texture as a unity TextureImporter .meta file
+// with the texture sliced into multiple sprites. Unity measures rects and
+// pivots from the bottom-left corner, so t.Size must be set.
+func WriteUnityMeta(w io.Writer, t Texture) error {
+	if t.Size.Y <= 0 {
+		return fmt.Errorf("texture height is required to flip regions into unity coordinates")
+	}
+	meta := unityMeta{
+		FileFormatVersion: 2,
+		GUID:              unityID(t.Image),
+		TextureImporter: unityTextureImporter{
+			SerializedVersion:   11,
+			TextureType:         unityTextureTypeSprite,
+			SpriteMode:          unitySpriteModeMultiple,
+			SpritePixelsToUnits: 100,
+			SpritePivot:         unityVector2{X: 0.5, Y: 0.5},
+			SpriteSheet:         unitySpriteSheet{SerializedVersion: 2},
+		},
+	}
+	for _, region := range t.Regions {
+		if region.Rotated {
+			return fmt.Errorf("region %v is rotated; unity sprite rects cannot be rotated", region.Name)
+		}
+		pivot := unityPivot(region)
+		alignment := unityAlignment(pivot)
+		meta.TextureImporter.SpriteSheet.Sprites = append(meta.TextureImporter.SpriteSheet.Sprites, unitySprite{
+			SerializedVersion: 2,
+			Name:              region.Name,
+			Rect: unityRect{
+				SerializedVersion: 2,
+				X:                 region.Rect.Min.X,
+				Y:                 t.Size.Y - region.Rect.Max.Y,
+				Width:             region.Rect.Dx(),
+				Height:            region.Rect.Dy(),
+			},
+			Alignment: alignment,
+			Pivot:     pivot,
+			Border: unityVector4{
+				X: region.Border.Left,
+				Y: region.Border.Bottom,
+				Z: region.Border.Right,
+				W: region.Border.Top,
+			},
+			SpriteID: unityID(t.Image + "/" + region.Name),
+		})
+	}
+	//yaml.v2 quotes "y" keys (a yaml 1.1 boolean), which unity's own
+	//serializer never does, so the meta is rendered with a template
+	return unityMetaTemplate.Execute(w, meta)
+}
+
+// ReadUnityMeta decodes the sprite rects of a unity .meta file. The meta
+// does not record the texture size, so the height of the image it belongs
+// to is needed to flip the rects back to a top-left origin.
+func ReadUnityMeta(r io.Reader, height int) (Texture, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Texture{}, fmt.Errorf("reading unity meta: %v", err)
+	}
+	var meta unityMeta
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return Texture{}, fmt.Errorf("unmarshalling unity meta: %v", err)
+	}
+	if meta.TextureImporter.SpriteMode != unitySpriteModeMultiple {
+		return Texture{}, fmt.Errorf("unity meta has spriteMode %v; only sliced sheets (spriteMode %v) contain sprite rects",
+			meta.TextureImporter.SpriteMode, unitySpriteModeMultiple)
+	}
+	t := Texture{Size: image.Pt(0, height)}
+	for _, sprite := range meta.TextureImporter.SpriteSheet.Sprites {
+		rect := sprite.Rect
+		top := height - rect.Y - rect.Height
+		pivot := sprite.Pivot
+		if sprite.Alignment >= 0 && sprite.Alignment < len(unityAlignments) {
+			//unity ignores the stored pivot unless the alignment is custom
+			p := unityAlignments[sprite.Alignment]
+			pivot = unityVector2{X: p.X, Y: p.Y}
+		}
+		t.Regions = append(t.Regions, Region{
+			Name:  sprite.Name,
+			Rect:  image.Rect(rect.X, top, rect.X+rect.Width, top+rect.Height),
+			Pivot: &Pivot{X: roundPivot(pivot.X), Y: roundPivot(1 - pivot.Y)},
+			Border: Border{
+				Left:   sprite.Border.X,
+				Top:    sprite.Border.W,
+				Right:  sprite.Border.Z,
+				Bottom: sprite.Border.Y,
+			},
+		})
+	}
+	return t, nil
+}
+
+// converts a region's pivot into unity's space: relative to the (possibly
+// trimmed) rect rather than the source image, with y pointing up
+func unityPivot(region Region) unityVector2 {
+	pivot := region.PivotOrCenter()
+	source := region.Source()
+	size := region.Rect.Size()
+	px := pivot.X*float64(source.X) - float64(region.Offset.X)
+	py := pivot.Y*float64(source.Y) - float64(region.Offset.Y)
+	if size.X == 0 || size.Y == 0 {
+		return unityVector2{X: 0.5, Y: 0.5}
+	}
+	return unityVector2{
+		X: roundPivot(px / float64(size.X)),
+		Y: roundPivot(1 - py/float64(size.Y)),
+	}
+}
+
+// keeps flipped pivots from picking up float noise such as 0.30000000000000004
+func roundPivot(f float64) float64 {
+	rounded := math.Round(f*1e6) / 1e6
+	if rounded == 0 {
+		//noise just below zero rounds to -0
+		return 0
+	}
+	return rounded
+}
+
+func unityAlignment(pivot unityVector2) int {
+	for i, p := range unityAlignments {
+		if p.X == pivot.X && p.Y == pivot.Y {
+			return i
+		}
+	}
+	return unityCustomAlignment
+}
+
+// unity ids are 32 hex digits; derive them from names so that re-exporting
+// the same sheet does not break references held by unity assets
+func unityID(name string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(name)))
+}
@@ -0,0 +1,177 @@
+package formats
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestUnityMetaRoundTrip(t *testing.T) {
+	texture := Texture{
+		Image: "hero.png",
+		Size:  image.Pt(128, 64),
+		Regions: []Region{
+			//no pivot: unity's center alignment
+			{Name: "center", Rect: image.Rect(10, 4, 42, 36)},
+			//bottom center in top-left coordinates is unity's enum value 7
+			{Name: "feet", Rect: image.Rect(42, 4, 74, 36), Pivot: &Pivot{X: 0.5, Y: 1}},
+			//1 - 0.7 is 0.30000000000000004 before rounding
+			{Name: "custom", Rect: image.Rect(74, 0, 84, 10), Pivot: &Pivot{X: 0.3, Y: 0.7}},
+			//the pivot is measured on the 40x40 source but unity wants it
+			//relative to the 32x24 trimmed rect
+			{
+				Name:       "trimmed",
+				Rect:       image.Rect(84, 40, 116, 64),
+				Trimmed:    true,
+				Offset:     image.Pt(4, 8),
+				SourceSize: image.Pt(40, 40),
+				Pivot:      &Pivot{X: 0.5, Y: 1},
+			},
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteUnityMeta(&buf, texture); err != nil {
+		t.Fatal(err)
+	}
+	meta := buf.String()
+	for _, want := range []string{
+		//64 - 36: rects are flipped against the texture height
+		"name: \"center\"\n      rect:\n        serializedVersion: 2\n        x: 10\n        y: 28\n",
+		"alignment: 0\n      pivot: {x: 0.5, y: 0.5}",
+		"alignment: 7\n      pivot: {x: 0.5, y: 0}",
+		"alignment: 9\n      pivot: {x: 0.3, y: 0.3}",
+		"name: \"trimmed\"\n      rect:\n        serializedVersion: 2\n        x: 84\n        y: 0\n",
+		"alignment: 9\n      pivot: {x: 0.5, y: -0.333333}",
+	} {
+		if !strings.Contains(meta, want) {
+			t.Errorf("meta does not contain %q:\n%v", want, meta)
+		}
+	}
+
+	read, err := ReadUnityMeta(&buf, texture.Size.Y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Regions) != len(texture.Regions) {
+		t.Fatalf("read %v regions, want %v", len(read.Regions), len(texture.Regions))
+	}
+	wantPivots := []Pivot{
+		{X: 0.5, Y: 0.5},
+		{X: 0.5, Y: 1},
+		{X: 0.3, Y: 0.7},
+		//unity does not keep the trim, so the pivot comes back relative to
+		//the rect: 32 of 24 pixels down
+		{X: 0.5, Y: 1.333333},
+	}
+	for i, region := range read.Regions {
+		if region.Name != texture.Regions[i].Name || region.Rect != texture.Regions[i].Rect {
+			t.Errorf("region %v read as %v %v, want %v %v", i,
+				region.Name, region.Rect, texture.Regions[i].Name, texture.Regions[i].Rect)
+		}
+		if region.Pivot == nil || *region.Pivot != wantPivots[i] {
+			t.Errorf("region %v pivot read as %v, want %v", region.Name, region.Pivot, wantPivots[i])
+		}
+	}
+}
+
+func TestReadUnityMetaAlignment(t *testing.T) {
+	//unity ignores the stored pivot unless alignment is custom (9)
+	meta := `fileFormatVersion: 2
+guid: 0
+TextureImporter:
+  spriteMode: 2
+  spriteSheet:
+    sprites:
+    - name: bottomleft
+      rect: {x: 0, y: 0, width: 16, height: 16}
+      alignment: 6
+      pivot: {x: 0.5, y: 0.5}
+    - name: custom
+      rect: {x: 16, y: 0, width: 16, height: 16}
+      alignment: 9
+      pivot: {x: 0.25, y: 0.75}
+`
+	texture, err := ReadUnityMeta(strings.NewReader(meta), 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		rect  image.Rectangle
+		pivot Pivot
+	}{
+		{image.Rect(0, 16, 16, 32), Pivot{X: 0, Y: 1}},
+		{image.Rect(16, 16, 32, 32), Pivot{X: 0.25, Y: 0.25}},
+	}
+	for i, region := range texture.Regions {
+		if region.Rect != want[i].rect || *region.Pivot != want[i].pivot {
+			t.Errorf("%v read as %v %v, want %v %v", region.Name, region.Rect, *region.Pivot, want[i].rect, want[i].pivot)
+		}
+	}
+}
+
+func TestUnityAlignmentsRoundTrip(t *testing.T) {
+	texture := Texture{Image: "hero.png", Size: image.Pt(16*len(unityAlignments), 16)}
+	for i, p := range unityAlignments {
+		texture.Regions = append(texture.Regions, Region{
+			Name: fmt.Sprintf("aligned%v", i),
+			Rect: image.Rect(16*i, 0, 16*i+16, 16),
+			//unity's y points up
+			Pivot: &Pivot{X: p.X, Y: 1 - p.Y},
+		})
+	}
+	var buf bytes.Buffer
+	if err := WriteUnityMeta(&buf, texture); err != nil {
+		t.Fatal(err)
+	}
+	for i := range unityAlignments {
+		want := fmt.Sprintf("name: \"aligned%v\"\n      rect:\n        serializedVersion: 2\n        x: %v\n        y: 0\n        width: 16\n        height: 16\n      alignment: %v\n", i, 16*i, i)
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("aligned%v is not written with alignment %v:\n%v", i, i, buf.String())
+		}
+	}
+	read, err := ReadUnityMeta(&buf, texture.Size.Y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, region := range read.Regions {
+		if *region.Pivot != *texture.Regions[i].Pivot {
+			t.Errorf("%v pivot read as %v, want %v", region.Name, *region.Pivot, *texture.Regions[i].Pivot)
+		}
+	}
+}
+
+func TestUnityMetaPlainFloats(t *testing.T) {
+	texture := Texture{
+		Image: "hero.png",
+		Size:  image.Pt(16, 16),
+		Regions: []Region{
+			{Name: "edge", Rect: image.Rect(0, 0, 16, 16), Pivot: &Pivot{X: 0.000001, Y: 1.0000004}},
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteUnityMeta(&buf, texture); err != nil {
+		t.Fatal(err)
+	}
+	if want := "pivot: {x: 0.000001, y: 0}"; !strings.Contains(buf.String(), want) {
+		t.Errorf("meta does not contain %q:\n%v", want, buf.String())
+	}
+	if strings.Contains(buf.String(), "e-") {
+		t.Errorf("meta has a float in exponent notation:\n%v", buf.String())
+	}
+	read, err := ReadUnityMeta(&buf, texture.Size.Y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := read.Regions[0].Pivot.X; got != 0.000001 {
+		t.Errorf("pivot x read as %v, want 0.000001", got)
+	}
+}
+
+func TestWriteUnityMetaNeedsHeight(t *testing.T) {
+	texture := Texture{Regions: []Region{{Name: "a", Rect: image.Rect(0, 0, 8, 8)}}}
+	if err := WriteUnityMeta(&bytes.Buffer{}, texture); err == nil {
+		t.Error("expected an error without a texture height")
+	}
+}
@@ -1,41 +1,55 @@
-package main
+package guide
 
 import (
 	"os"
 	"fmt"
 	"path/filepath"
 	"log"
-	"image/png"
 	"io/ioutil"
 	"github.com/ilackarms/sprite-locator/models"
 	"encoding/json"
 	"image"
-	"flag"
 	"sort"
 	"image/color"
 	"math"
 	"github.com/golang/freetype"
+	"github.com/ilackarms/sprite-locator/cli"
+	"github.com/ilackarms/sprite-locator/imagefile"
+	"github.com/ilackarms/sprite-locator/output"
+	"golang.org/x/image/font/gofont/goregular"
 )
 
 var spriteMargin int
 
-func main() {
-	marginPtr := flag.Int("margin", 0, "margin around sprites for raytrace")
-	outPtr := flag.String("out", "out", "output directory")
-	jsonPtr := flag.String("json", "", "json file")
-	imagePtr := flag.String("image", "", "image file")
-	flag.Parse()
-	spriteMargin = *marginPtr
+var Command = cli.Command{
+	Name:    "guide",
+	Summary: "sort boxes into rows and draw them, numbered, over the sheet",
+	Usage:   "-image <image> -boxes <bounds.json> [-out <outdir>] [-margin int]",
+}
+
+func init() {
+	Command.Run = run
+}
+
+func run(args []string) error {
+	fs := cli.NewFlagSet(Command)
+	fs.IntVar(&spriteMargin, "margin", 0, "margin around sprites for raytrace")
+	outPtr := fs.String("out", "out", "output directory")
+	jsonPtr := fs.String("boxes", "", "boxes json file")
+	fs.StringVar(jsonPtr, "json", "", "alias of -boxes")
+	imagePtr := fs.String("image", "", "image file")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
 
 	if *imagePtr == "" || *jsonPtr == "" {
-		fmt.Println("usage: guide -image <image.png> -json <bounds.json> [-out <outdir>] [-margin int]")
-		fmt.Printf("you gave me: %v\n", os.Args)
-		os.Exit(-1)
+		return cli.Usagef("-image and -boxes are required")
 	}
 	if err := guide(*imagePtr, *jsonPtr, *outPtr); err != nil {
-		log.Fatal(err)
+		return err
 	}
 	log.Print("OK")
+	return nil
 }
 
 func guide(imgFile, jsonFile, outDir string) error {
@@ -47,13 +61,9 @@ func guide(imgFile, jsonFile, outDir string) error {
 		return fmt.Errorf("abs path %v: %v", imgFile, err)
 	}
 	log.Printf("reading image at %v", path)
-	reader, err := os.Open(path)
-	if err != nil {
-		return fmt.Errorf("open %v: %v", path, err)
-	}
-	img, err := png.Decode(reader)
+	img, err := imagefile.Open(path)
 	if err != nil {
-		return fmt.Errorf("reading err: %v", err)
+		return err
 	}
 
 	raw, err := ioutil.ReadFile(jsonFile)
@@ -68,10 +78,11 @@ func guide(imgFile, jsonFile, outDir string) error {
 	if err := writeSheet(sortedSpritesheet, filepath.Join(outDir, jsonFile)); err != nil {
 		return fmt.Errorf("overwriting spritesheet: %v", err)
 	}
-	return drawGuides(img, &sortedSpritesheet, filepath.Join(outDir, imgFile))
+	return drawGuides(img, &sortedSpritesheet, imagefile.OutputName(filepath.Join(outDir, imgFile), ".png"))
 }
 
 func sortSheet(sheet *models.Spritesheet) models.Spritesheet {
+	unsorted := append([]models.Sprite{}, sheet.Sprites...)
 	sortedSprites := []models.Sprite{}
 	min, max := getBounds(sheet)
 	for len(sheet.Sprites) > 0 {
@@ -79,7 +90,36 @@ func sortSheet(sheet *models.Spritesheet) models.Spritesheet {
 		sortedSprites = append(sortedSprites, popTopRow(sheet, min, max)...)
 		log.Printf("%v done, %v unsorted remaining", len(sortedSprites), len(sheet.Sprites))
 	}
-	return models.Spritesheet{Sprites: sortedSprites}
+	return models.Spritesheet{
+		Sprites:    sortedSprites,
+		Duplicates: renumberDuplicates(sheet.Duplicates, unsorted, sortedSprites),
+	}
+}
+
+//duplicate groups refer to sprites by index, which sorting changes
+func renumberDuplicates(groups []models.DuplicateGroup, before, after []models.Sprite) []models.DuplicateGroup {
+	newIndex := make(map[models.Sprite]int)
+	for i, sprite := range after {
+		newIndex[sprite] = i
+	}
+	var renumbered []models.DuplicateGroup
+	for _, group := range groups {
+		var sprites []int
+		for _, i := range group.Sprites {
+			if i >= 0 && i < len(before) {
+				sprites = append(sprites, newIndex[before[i]])
+			}
+		}
+		if len(sprites) < 2 {
+			continue
+		}
+		sort.Ints(sprites)
+		renumbered = append(renumbered, models.DuplicateGroup{Sprites: sprites, Exact: group.Exact})
+	}
+	sort.Slice(renumbered, func(i, j int) bool {
+		return renumbered[i].Sprites[0] < renumbered[j].Sprites[0]
+	})
+	return renumbered
 }
 
 // like raycasting
@@ -190,7 +230,7 @@ func writeSheet(sprites models.Spritesheet, outFile string) error {
 		return fmt.Errorf("marshalling sprite sheet metadata: %v", err)
 	}
 
-	if err := ioutil.WriteFile(outFile, data, 0644); err != nil {
+	if err := output.WriteFile(outFile, data); err != nil {
 		return fmt.Errorf("writing sprite sheet metadata: %v", err)
 	}
 	return nil
@@ -238,7 +278,7 @@ func drawGuides(img image.Image, sheet *models.Spritesheet, outFile string) erro
 
 	fontBytes, err := ioutil.ReadFile(os.Getenv("HOME")+"/workspace/scratch/fonts/Lato-Regular.ttf")
 	if err != nil {
-		return err
+		fontBytes = goregular.TTF
 	}
 	f, err := freetype.ParseFont(fontBytes)
 	if err != nil {
@@ -260,18 +300,7 @@ func drawGuides(img image.Image, sheet *models.Spritesheet, outFile string) erro
 		}
 	}
 
-	//create or open file
-	out, err := os.Create(outFile)
-	if err != nil {
-		log.Printf("WARN: creating file: %v", err)
-		//open
-		out, err = os.Open(outFile)
-		if err != nil {
-			return fmt.Errorf("opening existing file: %v", err)
-		}
-	}
-
-	return png.Encode(out, newImage)
+	return imagefile.Save(outFile, newImage)
 }
 
 func boundingBoxPixels(sprite models.Sprite) []image.Point {
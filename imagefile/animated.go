@@ -0,0 +1,449 @@
+package imagefile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Animated is every frame of an animated GIF or APNG, each composited onto
+// the full canvas the way a viewer would show it.
+type Animated struct {
+	Frames []*image.RGBA
+	//milliseconds each frame is shown
+	Delays []int
+	//times to play the animation; 0 loops forever
+	Loops int
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// OpenAnimated decodes an animated GIF or APNG. A still PNG or GIF is read
+// as an animation with a single frame.
+func OpenAnimated(path string) (Animated, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Animated{}, fmt.Errorf("reading %v: %v", path, err)
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		return DecodeGIF(bytes.NewReader(data))
+	case bytes.HasPrefix(data, pngSignature):
+		return DecodeAPNG(bytes.NewReader(data))
+	}
+	return Animated{}, fmt.Errorf("%v is not a gif or png", path)
+}
+
+// DecodeGIF composites the frames of a GIF, honoring each frame's disposal
+// method. Disposal to background clears to transparent, as browsers do.
+func DecodeGIF(r io.Reader) (Animated, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return Animated{}, fmt.Errorf("decoding gif: %v", err)
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	animated := Animated{Loops: gifLoops(g.LoopCount)}
+	for i, frame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = cloneRGBA(canvas)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		animated.Frames = append(animated.Frames, cloneRGBA(canvas))
+		animated.Delays = append(animated.Delays, g.Delay[i]*10)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	if len(animated.Frames) == 0 {
+		return Animated{}, errors.New("gif has no frames")
+	}
+	return animated, nil
+}
+
+//gif stores -1 for play once and 0 for forever; Animated counts plays
+func gifLoops(loopCount int) int {
+	switch {
+	case loopCount < 0:
+		return 1
+	case loopCount == 0:
+		return 0
+	}
+	return loopCount + 1
+}
+
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+//an APNG frame control chunk
+type fcTL struct {
+	width, height, x, y uint32
+	delayNum, delayDen  uint16
+	dispose, blend      byte
+}
+
+const (
+	apngDisposeNone = iota
+	apngDisposeBackground
+	apngDisposePrevious
+)
+
+const apngBlendOver = 1
+
+// DecodeAPNG composites the frames of an APNG. image/png only understands
+// the default image, so each frame's data is rewrapped as a standalone PNG
+// with the frame's size and decoded on its own. A PNG without an acTL chunk
+// decodes as a single frame.
+func DecodeAPNG(r io.Reader) (Animated, error) {
+	chunks, err := readPNGChunks(r)
+	if err != nil {
+		return Animated{}, err
+	}
+	var (
+		ihdr     []byte
+		shared   []pngChunk
+		animated bool
+		loops    int
+		controls []fcTL
+		frames   [][]byte
+		seenIDAT bool
+	)
+	//frame data is only collected once its fcTL has been read; an IDAT
+	//without one before it is a default image that is not part of the animation
+	current := -1
+	for _, c := range chunks {
+		switch c.typ {
+		case "IHDR":
+			ihdr = c.data
+		case "acTL":
+			if len(c.data) < 8 {
+				return Animated{}, errors.New("apng: short acTL chunk")
+			}
+			animated = true
+			loops = int(binary.BigEndian.Uint32(c.data[4:8]))
+		case "fcTL":
+			control, err := parseFCTL(c.data)
+			if err != nil {
+				return Animated{}, err
+			}
+			controls = append(controls, control)
+			frames = append(frames, nil)
+			current = len(frames) - 1
+		case "IDAT":
+			seenIDAT = true
+			if current >= 0 {
+				frames[current] = append(frames[current], c.data...)
+			}
+		case "fdAT":
+			if len(c.data) < 4 {
+				return Animated{}, errors.New("apng: short fdAT chunk")
+			}
+			if current >= 0 {
+				frames[current] = append(frames[current], c.data[4:]...)
+			}
+		case "IEND":
+		default:
+			//palette, transparency and color space chunks apply to every frame
+			if !seenIDAT {
+				shared = append(shared, c)
+			}
+		}
+	}
+	if ihdr == nil || len(ihdr) < 13 {
+		return Animated{}, errors.New("png has no IHDR chunk")
+	}
+	width, height := binary.BigEndian.Uint32(ihdr[0:4]), binary.BigEndian.Uint32(ihdr[4:8])
+	if !animated || len(controls) == 0 {
+		var buf bytes.Buffer
+		for _, c := range chunks {
+			writePNGChunk(&buf, c)
+		}
+		img, err := png.Decode(io.MultiReader(bytes.NewReader(pngSignature), &buf))
+		if err != nil {
+			return Animated{}, fmt.Errorf("decoding png: %v", err)
+		}
+		canvas := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+		draw.Draw(canvas, canvas.Bounds(), img, img.Bounds().Min, draw.Src)
+		return Animated{Frames: []*image.RGBA{canvas}, Delays: []int{0}, Loops: 1}, nil
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	result := Animated{Loops: loops}
+	for i, control := range controls {
+		frame, err := decodeAPNGFrame(ihdr, shared, control, frames[i])
+		if err != nil {
+			return Animated{}, fmt.Errorf("apng frame %v: %v", i, err)
+		}
+		bounds := image.Rect(int(control.x), int(control.y), int(control.x+control.width), int(control.y+control.height))
+		dispose := control.dispose
+		if i == 0 && dispose == apngDisposePrevious {
+			dispose = apngDisposeBackground
+		}
+		var previous *image.RGBA
+		if dispose == apngDisposePrevious {
+			previous = cloneRGBA(canvas)
+		}
+		op := draw.Src
+		if control.blend == apngBlendOver {
+			op = draw.Over
+		}
+		draw.Draw(canvas, bounds, frame, frame.Bounds().Min, op)
+		result.Frames = append(result.Frames, cloneRGBA(canvas))
+		result.Delays = append(result.Delays, control.delay())
+
+		switch dispose {
+		case apngDisposeBackground:
+			draw.Draw(canvas, bounds, image.Transparent, image.Point{}, draw.Src)
+		case apngDisposePrevious:
+			canvas = previous
+		}
+	}
+	return result, nil
+}
+
+func parseFCTL(data []byte) (fcTL, error) {
+	if len(data) < 26 {
+		return fcTL{}, errors.New("apng: short fcTL chunk")
+	}
+	return fcTL{
+		width:    binary.BigEndian.Uint32(data[4:8]),
+		height:   binary.BigEndian.Uint32(data[8:12]),
+		x:        binary.BigEndian.Uint32(data[12:16]),
+		y:        binary.BigEndian.Uint32(data[16:20]),
+		delayNum: binary.BigEndian.Uint16(data[20:22]),
+		delayDen: binary.BigEndian.Uint16(data[22:24]),
+		dispose:  data[24],
+		blend:    data[25],
+	}, nil
+}
+
+//a zero denominator means hundredths of a second
+func (c fcTL) delay() int {
+	den := int(c.delayDen)
+	if den == 0 {
+		den = 100
+	}
+	return int(c.delayNum) * 1000 / den
+}
+
+func decodeAPNGFrame(ihdr []byte, shared []pngChunk, control fcTL, data []byte) (image.Image, error) {
+	if len(data) == 0 {
+		return nil, errors.New("no image data")
+	}
+	header := append([]byte(nil), ihdr...)
+	binary.BigEndian.PutUint32(header[0:4], control.width)
+	binary.BigEndian.PutUint32(header[4:8], control.height)
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+	writePNGChunk(&buf, pngChunk{"IHDR", header})
+	for _, c := range shared {
+		writePNGChunk(&buf, c)
+	}
+	writePNGChunk(&buf, pngChunk{"IDAT", data})
+	writePNGChunk(&buf, pngChunk{"IEND", nil})
+	return png.Decode(&buf)
+}
+
+func readPNGChunks(r io.Reader) ([]pngChunk, error) {
+	br := bufio.NewReader(r)
+	signature := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(br, signature); err != nil || !bytes.Equal(signature, pngSignature) {
+		return nil, errors.New("not a png")
+	}
+	var chunks []pngChunk
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			if err == io.EOF && len(chunks) > 0 {
+				return chunks, nil
+			}
+			return nil, fmt.Errorf("reading png chunk: %v", err)
+		}
+		length := binary.BigEndian.Uint32(header[0:4])
+		data := make([]byte, length+4)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, fmt.Errorf("reading png chunk %s: %v", header[4:8], err)
+		}
+		c := pngChunk{typ: string(header[4:8]), data: data[:length]}
+		chunks = append(chunks, c)
+		if c.typ == "IEND" {
+			return chunks, nil
+		}
+	}
+}
+
+func writePNGChunk(w io.Writer, c pngChunk) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(c.data)))
+	copy(header[4:8], c.typ)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:8])
+	crc.Write(c.data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	w.Write(header[:])
+	w.Write(c.data)
+	w.Write(sum[:])
+}
+
+func cloneRGBA(img *image.RGBA) *image.RGBA {
+	clone := image.NewRGBA(img.Bounds())
+	copy(clone.Pix, img.Pix)
+	return clone
+}
+
+// EncodeAnimated writes an animation as a GIF, or as an APNG for a .png or
+// .apng name. Every frame is mapped onto one palette shared by the whole
+// animation, so colors do not shift from frame to frame.
+func EncodeAnimated(w io.Writer, a Animated, name string) error {
+	if len(a.Frames) == 0 {
+		return errors.New("animation has no frames")
+	}
+	images := make([]image.Image, len(a.Frames))
+	for i, frame := range a.Frames {
+		images[i] = frame
+	}
+	pal := Palette(images...)
+	var paletted []*image.Paletted
+	for _, frame := range a.Frames {
+		paletted = append(paletted, ToPaletted(frame, pal))
+	}
+	switch ext := strings.ToLower(filepath.Ext(name)); ext {
+	case ".gif":
+		return encodeGIF(w, paletted, a.Delays, a.Loops)
+	case ".png", ".apng":
+		return encodeAPNG(w, paletted, a.Delays, a.Loops)
+	default:
+		return fmt.Errorf("cannot write animation %v: use a .gif, .png or .apng extension", name)
+	}
+}
+
+func encodeGIF(w io.Writer, frames []*image.Paletted, delays []int, loops int) error {
+	g := &gif.GIF{Image: frames}
+	for range frames {
+		//frames are whole canvases with transparent pixels, which must not
+		//show the previous frame through
+		g.Disposal = append(g.Disposal, gif.DisposalBackground)
+	}
+	for _, delay := range delays {
+		g.Delay = append(g.Delay, (clampDelay(delay)+5)/10)
+	}
+	switch loops {
+	case 0:
+		g.LoopCount = 0
+	case 1:
+		g.LoopCount = -1
+	default:
+		g.LoopCount = loops - 1
+	}
+	return gif.EncodeAll(w, g)
+}
+
+const (
+	//viewers play shorter delays, zero included, at a speed of their own
+	//choosing, often 100ms
+	minDelay = 10
+	//the longest delay a gif can store, 65535 hundredths of a second
+	maxDelay = 65535 * 10
+)
+
+func clampDelay(ms int) int {
+	if ms < minDelay {
+		return minDelay
+	}
+	if ms > maxDelay {
+		return maxDelay
+	}
+	return ms
+}
+
+//apngDelay is a delay as the 16 bit fraction of a second an fcTL chunk
+//holds: milliseconds when they fit, hundredths of a second when they do not
+func apngDelay(ms int) (num, den uint16) {
+	ms = clampDelay(ms)
+	if ms <= 65535 {
+		return uint16(ms), 1000
+	}
+	return uint16((ms + 5) / 10), 100
+}
+
+// encodeAPNG encodes each frame as a standalone png and moves its image data
+// into fcTL/fdAT chunks after the first frame's header and palette. The
+// shared palette keeps every frame's header identical.
+func encodeAPNG(w io.Writer, frames []*image.Paletted, delays []int, loops int) error {
+	var out bytes.Buffer
+	out.Write(pngSignature)
+	var seq uint32
+	for i, frame := range frames {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, frame); err != nil {
+			return fmt.Errorf("encoding frame %v: %v", i, err)
+		}
+		chunks, err := readPNGChunks(&buf)
+		if err != nil {
+			return err
+		}
+		var data []byte
+		for _, c := range chunks {
+			switch c.typ {
+			case "IDAT":
+				data = append(data, c.data...)
+			case "IEND":
+			default:
+				if i == 0 {
+					writePNGChunk(&out, c)
+				}
+			}
+			if i == 0 && c.typ == "IHDR" {
+				actl := make([]byte, 8)
+				binary.BigEndian.PutUint32(actl[0:4], uint32(len(frames)))
+				binary.BigEndian.PutUint32(actl[4:8], uint32(loops))
+				writePNGChunk(&out, pngChunk{"acTL", actl})
+			}
+		}
+		size := frame.Bounds().Size()
+		control := make([]byte, 26)
+		binary.BigEndian.PutUint32(control[0:4], seq)
+		binary.BigEndian.PutUint32(control[4:8], uint32(size.X))
+		binary.BigEndian.PutUint32(control[8:12], uint32(size.Y))
+		num, den := apngDelay(delays[i])
+		binary.BigEndian.PutUint16(control[20:22], num)
+		binary.BigEndian.PutUint16(control[22:24], den)
+		control[24] = apngDisposeNone
+		//the default blend op replaces the whole canvas, transparency included
+		writePNGChunk(&out, pngChunk{"fcTL", control})
+		seq++
+		if i == 0 {
+			writePNGChunk(&out, pngChunk{"IDAT", data})
+			continue
+		}
+		fdat := make([]byte, 4, 4+len(data))
+		binary.BigEndian.PutUint32(fdat, seq)
+		writePNGChunk(&out, pngChunk{"fdAT", append(fdat, data...)})
+		seq++
+	}
+	writePNGChunk(&out, pngChunk{"IEND", nil})
+	_, err := w.Write(out.Bytes())
+	return err
+}
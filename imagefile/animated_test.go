@@ -0,0 +1,143 @@
+package imagefile
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+var (
+	red   = color.RGBA{R: 255, A: 255}
+	green = color.RGBA{G: 255, A: 255}
+	blue  = color.RGBA{B: 255, A: 255}
+)
+
+var testPalette = color.Palette{color.RGBA{}, red, green, blue}
+
+func filled(rect image.Rectangle, c color.Color) *image.Paletted {
+	img := image.NewPaletted(rect, testPalette)
+	index := uint8(testPalette.Index(c))
+	for i := range img.Pix {
+		img.Pix[i] = index
+	}
+	return img
+}
+
+//a red 4x4 canvas, a green 2x2 patch in its corner disposed of by
+//disposal, then a blue pixel in the opposite corner
+func disposalGIF(t *testing.T, disposal byte) Animated {
+	g := &gif.GIF{
+		Image: []*image.Paletted{
+			filled(image.Rect(0, 0, 4, 4), red),
+			filled(image.Rect(0, 0, 2, 2), green),
+			filled(image.Rect(3, 3, 4, 4), blue),
+		},
+		Delay:    []int{1, 2, 3},
+		Disposal: []byte{gif.DisposalNone, disposal, gif.DisposalNone},
+		Config:   image.Config{ColorModel: testPalette, Width: 4, Height: 4},
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	animated, err := DecodeGIF(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(animated.Frames) != 3 {
+		t.Fatalf("decoded %v frames, want 3", len(animated.Frames))
+	}
+	if want := []int{10, 20, 30}; !equalInts(animated.Delays, want) {
+		t.Errorf("delays %v, want %v", animated.Delays, want)
+	}
+	return animated
+}
+
+func TestDecodeGIFDisposeBackground(t *testing.T) {
+	frames := disposalGIF(t, gif.DisposalBackground).Frames
+	checkPixel(t, frames[1], 0, 0, green)
+	checkPixel(t, frames[1], 3, 3, red)
+	//the patch is cleared to transparent, the rest of the canvas is kept
+	checkPixel(t, frames[2], 0, 0, color.RGBA{})
+	checkPixel(t, frames[2], 1, 1, color.RGBA{})
+	checkPixel(t, frames[2], 2, 2, red)
+	checkPixel(t, frames[2], 3, 3, blue)
+}
+
+func TestDecodeGIFDisposePrevious(t *testing.T) {
+	frames := disposalGIF(t, gif.DisposalPrevious).Frames
+	checkPixel(t, frames[1], 0, 0, green)
+	//the red under the patch is restored
+	checkPixel(t, frames[2], 0, 0, red)
+	checkPixel(t, frames[2], 1, 1, red)
+	checkPixel(t, frames[2], 3, 3, blue)
+}
+
+func TestAPNGRoundTrip(t *testing.T) {
+	first := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	second := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	first.Set(0, 0, red)
+	second.Set(2, 1, blue)
+	//the second frame's transparent pixels must not show the first's red
+	animated := Animated{Frames: []*image.RGBA{first, second}, Delays: []int{40, 70000}, Loops: 3}
+	var buf bytes.Buffer
+	if err := EncodeAnimated(&buf, animated, "walk.png"); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeAPNG(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Frames) != 2 || decoded.Loops != 3 {
+		t.Fatalf("decoded %v frames looping %v times, want 2 frames looping 3 times", len(decoded.Frames), decoded.Loops)
+	}
+	if !equalInts(decoded.Delays, animated.Delays) {
+		t.Errorf("delays %v, want %v", decoded.Delays, animated.Delays)
+	}
+	checkPixel(t, decoded.Frames[0], 0, 0, red)
+	checkPixel(t, decoded.Frames[0], 2, 1, color.RGBA{})
+	checkPixel(t, decoded.Frames[1], 0, 0, color.RGBA{})
+	checkPixel(t, decoded.Frames[1], 2, 1, blue)
+}
+
+func TestAPNGDelay(t *testing.T) {
+	for _, c := range []struct {
+		ms       int
+		num, den uint16
+	}{
+		{0, 10, 1000},
+		{-5, 10, 1000},
+		{100, 100, 1000},
+		{65535, 65535, 1000},
+		//too many milliseconds for 16 bits
+		{65536, 6554, 100},
+		{100000, 10000, 100},
+		{10000000, 65535, 100},
+	} {
+		num, den := apngDelay(c.ms)
+		if num != c.num || den != c.den {
+			t.Errorf("apngDelay(%v) = %v/%v, want %v/%v", c.ms, num, den, c.num, c.den)
+		}
+	}
+}
+
+func checkPixel(t *testing.T, img *image.RGBA, x, y int, want color.RGBA) {
+	t.Helper()
+	if got := img.RGBAAt(x, y); got != want {
+		t.Errorf("pixel (%v,%v) is %v, want %v", x, y, got, want)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+package imagefile
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+
+	"github.com/ilackarms/sprite-locator/output"
+)
+
+//reads and writes sheet images in every format the tools accept. decoders
+//register themselves with the image package, so image.Decode and
+//image.DecodeConfig anywhere in the binary understand all of them
+
+var decodable = map[string]bool{
+	".png":  true,
+	".gif":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".bmp":  true,
+	".tif":  true,
+	".tiff": true,
+	".webp": true,
+}
+
+// IsImage reports whether name has the extension of a format Open can decode.
+func IsImage(name string) bool {
+	return decodable[strings.ToLower(filepath.Ext(name))]
+}
+
+// CanEncode reports whether Encode can write a file named name.
+func CanEncode(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".png", ".gif", ".jpg", ".jpeg", ".bmp", ".tif", ".tiff":
+		return true
+	}
+	return false
+}
+
+// Open decodes the image at path, whatever its format.
+func Open(path string) (image.Image, error) {
+	reader, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %v: %v", path, err)
+	}
+	defer reader.Close()
+	img, _, err := image.Decode(reader)
+	if err != nil {
+		return nil, fmt.Errorf("reading err: %v", err)
+	}
+	return img, nil
+}
+
+// Size reads the width and height of the image at path without decoding
+// its pixels.
+func Size(path string) (image.Point, error) {
+	reader, err := os.Open(path)
+	if err != nil {
+		return image.Point{}, fmt.Errorf("reading image size: %v", err)
+	}
+	defer reader.Close()
+	config, _, err := image.DecodeConfig(reader)
+	if err != nil {
+		return image.Point{}, fmt.Errorf("reading image size of %v: %v", path, err)
+	}
+	return image.Pt(config.Width, config.Height), nil
+}
+
+// Encode writes img in the format named by the extension of name. PNG, GIF,
+// BMP and TIFF keep transparency (GIF only fully transparent pixels); JPEG
+// has no alpha channel, so transparent pixels are flattened onto white.
+func Encode(w io.Writer, img image.Image, name string) error {
+	switch ext := strings.ToLower(filepath.Ext(name)); ext {
+	case ".png":
+		return png.Encode(w, img)
+	case ".gif":
+		return gif.Encode(w, ToPaletted(img, Palette(img)), nil)
+	case ".jpg", ".jpeg":
+		return jpeg.Encode(w, Flatten(img, color.White), &jpeg.Options{Quality: 95})
+	case ".bmp":
+		return bmp.Encode(w, img)
+	case ".tif", ".tiff":
+		return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate})
+	case ".webp":
+		return fmt.Errorf("cannot write %v: webp images can be read but not written", name)
+	default:
+		return fmt.Errorf("cannot write %v: unknown image extension %q", name, ext)
+	}
+}
+
+// Save encodes img into path, in the format its extension names.
+func Save(path string, img image.Image) error {
+	return output.Write(path, func(w io.Writer) error {
+		return Encode(w, img, path)
+	})
+}
+
+// OutputName returns name with its extension swapped for ext when the
+// original cannot be encoded, so outputs derived from a webp input are
+// written as ext instead.
+func OutputName(name, ext string) string {
+	if CanEncode(name) {
+		return name
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name)) + ext
+}
+
+// Flatten draws img over a solid background, dropping its alpha.
+func Flatten(img image.Image, bg color.Color) *image.RGBA {
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+	return flat
+}
+
+// Palette builds a GIF palette shared by imgs: index 0 is transparent and
+// the rest are the opaque colors used, exactly when there are at most 255 of
+// them, or 255 colors picked from them by median cut otherwise.
+func Palette(imgs ...image.Image) color.Palette {
+	counts := map[color.RGBA]int{}
+	pal := color.Palette{color.RGBA{}}
+	for _, img := range imgs {
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c, ok := opaque(img.At(x, y))
+				if !ok {
+					continue
+				}
+				if counts[c] == 0 {
+					pal = append(pal, c)
+				}
+				counts[c]++
+			}
+		}
+	}
+	if len(pal) <= 256 {
+		return pal
+	}
+	log.Printf("WARN: %v colors do not fit a gif palette; reducing them to 255", len(counts))
+	return append(color.Palette{color.RGBA{}}, medianCut(counts, 255)...)
+}
+
+// ToPaletted maps img onto a palette from Palette: pixels less than half
+// opaque become index 0, everything else the nearest opaque color.
+func ToPaletted(img image.Image, pal color.Palette) *image.Paletted {
+	b := img.Bounds()
+	paletted := image.NewPaletted(b, pal)
+	opaquePal := pal[1:]
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c, ok := opaque(img.At(x, y))
+			if !ok {
+				continue
+			}
+			paletted.SetColorIndex(x, y, uint8(opaquePal.Index(c)+1))
+		}
+	}
+	return paletted
+}
+
+func opaque(c color.Color) (color.RGBA, bool) {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if n.A < 128 {
+		return color.RGBA{}, false
+	}
+	return color.RGBA{R: n.R, G: n.G, B: n.B, A: 255}, true
+}
@@ -0,0 +1,120 @@
+package imagefile
+
+import (
+	"image/color"
+	"sort"
+)
+
+//a color used count times
+type colorCount struct {
+	c     color.RGBA
+	count int
+}
+
+//a box of the color cube holding some of the colors used
+type colorBox []colorCount
+
+func channel(c color.RGBA, ch int) uint8 {
+	switch ch {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	}
+	return c.B
+}
+
+//widest returns the channel the box's colors spread over the most, and
+//how far
+func (b colorBox) widest() (int, int) {
+	best, bestRange := 0, -1
+	for ch := 0; ch < 3; ch++ {
+		lo, hi := 255, 0
+		for _, cc := range b {
+			v := int(channel(cc.c, ch))
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+		if hi-lo > bestRange {
+			best, bestRange = ch, hi-lo
+		}
+	}
+	return best, bestRange
+}
+
+//mean is the box's colors averaged by how often each is used
+func (b colorBox) mean() color.RGBA {
+	var r, g, bl, n int
+	for _, cc := range b {
+		r += int(cc.c.R) * cc.count
+		g += int(cc.c.G) * cc.count
+		bl += int(cc.c.B) * cc.count
+		n += cc.count
+	}
+	return color.RGBA{R: uint8((r + n/2) / n), G: uint8((g + n/2) / n), B: uint8((bl + n/2) / n), A: 255}
+}
+
+//medianCut picks n colors standing in for all those counted: the color
+//cube is split, widest box first, at the median pixel of the box's widest
+//channel, and each box becomes the average of its colors
+func medianCut(counts map[color.RGBA]int, n int) []color.Color {
+	all := make(colorBox, 0, len(counts))
+	for c, count := range counts {
+		all = append(all, colorCount{c, count})
+	}
+	//map order is random; the palette must not be
+	sort.Slice(all, func(i, j int) bool {
+		a, b := all[i].c, all[j].c
+		if a.R != b.R {
+			return a.R < b.R
+		}
+		if a.G != b.G {
+			return a.G < b.G
+		}
+		return a.B < b.B
+	})
+	boxes := []colorBox{all}
+	for len(boxes) < n {
+		split, splitRange, splitChannel := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if ch, r := box.widest(); r > splitRange {
+				split, splitRange, splitChannel = i, r, ch
+			}
+		}
+		if split < 0 {
+			break
+		}
+		box := boxes[split]
+		sort.SliceStable(box, func(i, j int) bool {
+			return channel(box[i].c, splitChannel) < channel(box[j].c, splitChannel)
+		})
+		var total int
+		for _, cc := range box {
+			total += cc.count
+		}
+		//the first color past half the box's pixels, leaving both halves
+		//at least one color
+		cut, seen := 1, 0
+		for i, cc := range box[:len(box)-1] {
+			seen += cc.count
+			if seen*2 >= total {
+				cut = i + 1
+				break
+			}
+		}
+		boxes[split] = box[:cut]
+		boxes = append(boxes, box[cut:])
+	}
+	pal := make([]color.Color, len(boxes))
+	for i, box := range boxes {
+		pal[i] = box.mean()
+	}
+	return pal
+}
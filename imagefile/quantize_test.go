@@ -0,0 +1,69 @@
+package imagefile
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+//a gradient of far more colors than a gif holds
+func gradient() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 4), G: uint8(y * 4), B: uint8((x + y) * 2), A: 255})
+		}
+	}
+	return img
+}
+
+func TestPaletteKeepsFewColors(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.RGBA{R: 10, G: 20, B: 30, A: 255})
+	pal := Palette(img)
+	want := color.Palette{color.RGBA{}, color.RGBA{R: 10, G: 20, B: 30, A: 255}}
+	if len(pal) != len(want) || pal[0] != want[0] || pal[1] != want[1] {
+		t.Errorf("palette %v, want %v", pal, want)
+	}
+}
+
+func TestPaletteQuantizesManyColors(t *testing.T) {
+	img := gradient()
+	pal := Palette(img)
+	if len(pal) != 256 {
+		t.Fatalf("palette has %v colors, want 256", len(pal))
+	}
+	if pal[0] != (color.RGBA{}) {
+		t.Errorf("index 0 is %v, not transparent", pal[0])
+	}
+	//the web-safe colors are 51 apart, so pixels can be 25 off them; median
+	//cut colors follow the image
+	paletted := ToPaletted(img, pal)
+	var worst int
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			want := img.RGBAAt(x, y)
+			got := color.RGBAModel.Convert(paletted.At(x, y)).(color.RGBA)
+			for _, d := range []int{int(got.R) - int(want.R), int(got.G) - int(want.G), int(got.B) - int(want.B)} {
+				if d < 0 {
+					d = -d
+				}
+				if d > worst {
+					worst = d
+				}
+			}
+		}
+	}
+	if worst > 20 {
+		t.Errorf("a pixel is %v off its color in the quantized image", worst)
+	}
+}
+
+func TestPaletteIsDeterministic(t *testing.T) {
+	first, second := Palette(gradient()), Palette(gradient())
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("palettes differ at %v: %v and %v", i, first[i], second[i])
+		}
+	}
+}
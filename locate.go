@@ -0,0 +1,421 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/ilackarms/sprite-locator/algorithm"
+	"github.com/ilackarms/sprite-locator/batch"
+	"github.com/ilackarms/sprite-locator/cli"
+	"github.com/ilackarms/sprite-locator/crop"
+	"github.com/ilackarms/sprite-locator/dedup"
+	"github.com/ilackarms/sprite-locator/imagefile"
+	"github.com/ilackarms/sprite-locator/models"
+	"github.com/ilackarms/sprite-locator/naming"
+	"github.com/ilackarms/sprite-locator/output"
+	"github.com/ilackarms/sprite-locator/pivot"
+	"github.com/ilackarms/sprite-locator/watch"
+)
+
+var locateCommand = cli.Command{
+	Name:    "locate",
+	Summary: "find the bounding box of every sprite in a sheet and write them as json",
+	Usage:   "[flags] <sheet.png> [<out.json>] | [flags] <sheet, directory or pattern>...",
+}
+
+var extractCommand = cli.Command{
+	Name:    "extract",
+	Summary: "locate sprites like locate, and also save each one in its own image",
+	Usage:   "[flags] <sheet.png> [<out.json>] | [flags] <sheet, directory or pattern>...",
+}
+
+func init() {
+	locateCommand.Run = func(args []string) error {
+		return runLocate(locateCommand, args, false)
+	}
+	extractCommand.Run = func(args []string) error {
+		return runLocate(extractCommand, args, true)
+	}
+}
+
+type locateOptions struct {
+	margin         int
+	minImageHeight int
+	extractSprites bool
+	crop           crop.Options
+	//make the detected background color transparent in extracted sprites
+	transparentBg bool
+	//copy only the pixels of each sprite's own components
+	mask bool
+	//file names of extracted sprites, relative to the sheet's directory
+	names naming.Names
+	//list sprites showing the same frame in the boxes json
+	dedup bool
+	//perceptual hash distance still counted as the same frame
+	nearDistance int
+	//list sprites that are horizontal mirrors of others in the boxes json
+	mirrors bool
+	//extract each frame once
+	unique bool
+	//let -unique drop near duplicates, not just identical ones
+	uniqueNear bool
+	//how each sprite's pivot is found, if at all
+	pivot pivot.Options
+}
+
+const defaultName = "{sheet}_{index}.png"
+
+func runLocate(cmd cli.Command, args []string, extract bool) error {
+	margin, err := cli.EnvInt("PIXEL_MARGIN", 4)
+	if err != nil {
+		return err
+	}
+	minImageHeight, err := cli.EnvInt("MIN_IMAGE_HEIGHT", 0)
+	if err != nil {
+		return err
+	}
+	var opts locateOptions
+	fs := cli.NewFlagSet(cmd)
+	fs.IntVar(&opts.margin, "margin", margin, "empty pixels allowed between pixels of one sprite (env PIXEL_MARGIN)")
+	fs.IntVar(&opts.minImageHeight, "min-height", minImageHeight, "ignore sprites shorter than this (env MIN_IMAGE_HEIGHT)")
+	outPtr := fs.String("out", "", "boxes json file (default <sheet>.json); single sheet only")
+	outDirPtr := fs.String("out-dir", "", "write outputs into a tree mirroring the inputs instead of next to them")
+	jobsPtr := fs.Int("jobs", runtime.NumCPU(), "sheets processed at once")
+	watching, watchOpts := watch.Flags(fs)
+	if !extract {
+		fs.BoolVar(&opts.extractSprites, "extract", cli.EnvBool("EXTRACT_SPRITES", false), "also save each sprite in its own image, named by -name (env EXTRACT_SPRITES)")
+	}
+	fs.BoolVar(&opts.dedup, "dedup", false, "find sprites showing the same frame and list them under duplicates in the boxes json")
+	fs.IntVar(&opts.nearDistance, "near", dedup.DefaultMaxDistance, "dedup: perceptual hash bits (of 64) two sprites may differ in and still match, catching boxes a pixel apart; -1 matches identical pixels only")
+	fs.BoolVar(&opts.mirrors, "mirrors", false, "find sprites that are horizontal mirrors of others, e.g. east-facing frames of west-facing ones, and list them under mirrors in the boxes json")
+	fs.BoolVar(&opts.unique, "unique", false, "extract: save each frame once; duplicates point at the first copy in the offsets file, and with -mirrors mirrors point at their original with flip_x. Implies -dedup")
+	fs.BoolVar(&opts.uniqueNear, "unique-near", false, "extract -unique: also save only the first of sprites that match by perceptual hash (exact false), not just identical ones")
+	namePtr := fs.String("name", defaultName, "extract: sprite file name template relative to the sheet; fields are {sheet} and {index}, e.g. {sheet}/{index:03}.png")
+	fs.IntVar(&opts.crop.Padding, "padding", 0, "extract: transparent pixels around each sprite")
+	fs.BoolVar(&opts.crop.PowerOfTwo, "pot", false, "extract: grow each sprite image to power-of-two dimensions")
+	cellPtr := fs.String("cell", "", "extract: draw every sprite on a canvas of this size, e.g. 64x96")
+	anchorPtr := fs.String("anchor", string(crop.Center), "extract: where a sprite sits on a larger canvas: center, bottom or top-left")
+	fs.BoolVar(&opts.transparentBg, "transparent-bg", false, "extract: make the sheet's background color transparent")
+	fs.BoolVar(&opts.mask, "mask", false, "extract: copy only the sprite's own pixels, leaving background and parts of neighbouring sprites transparent")
+	pivotPtr := fs.String("pivot", "", "find each sprite's pivot and write it in pixels and as a fraction of its box: feet (bottom center of its pixels), centroid, or marker (a pixel painted in -marker-color)")
+	markerPtr := fs.String("marker-color", "#ff00ff", "pivot marker: color of the pixel marking each sprite's pivot")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+	if extract {
+		opts.extractSprites = true
+	}
+	if opts.uniqueNear && !opts.unique {
+		return cli.Usagef("-unique-near works with -unique")
+	}
+	if opts.unique {
+		opts.dedup = true
+	}
+	if opts.pivot.Mode, err = pivot.ParseMode(*pivotPtr); err != nil {
+		return cli.Usagef("-pivot: %v", err)
+	}
+	if opts.pivot.Mode == pivot.Marker {
+		if opts.pivot.Marker, err = pivot.ParseColor(*markerPtr); err != nil {
+			return cli.Usagef("-marker-color: %v", err)
+		}
+	}
+	if opts.crop.Anchor, err = crop.ParseAnchor(*anchorPtr); err != nil {
+		return cli.Usagef("%v", err)
+	}
+	if *cellPtr != "" {
+		if opts.crop.Cell, err = crop.ParseSize(*cellPtr); err != nil {
+			return cli.Usagef("-cell: %v", err)
+		}
+	}
+	tmpl, err := naming.Parse(*namePtr, naming.Sheet, naming.Index)
+	if err != nil {
+		return cli.Usagef("-name: %v", err)
+	}
+	if !imagefile.CanEncode(tmpl.String()) {
+		return cli.Usagef("-name: %q must end in an image extension that can be written, e.g. .png", *namePtr)
+	}
+	opts.names = naming.Names{Template: tmpl, Custom: *namePtr != defaultName}
+	if opts.crop.Padding < 0 {
+		return cli.Usagef("-padding cannot be negative")
+	}
+	args = fs.Args()
+	if len(args) == 0 {
+		return cli.Usagef("%s needs a sheet image, directory or pattern", cmd.Name)
+	}
+	//<sheet.png> <out.json> names the output of a single sheet
+	if len(args) == 2 && filepath.Ext(args[1]) == ".json" {
+		if *outPtr != "" {
+			return cli.Usagef("give the output file either with -out or as the second argument")
+		}
+		*outPtr = args[1]
+		args = args[:1]
+	}
+	if len(args) == 1 && *outDirPtr == "" && isFile(args[0]) {
+		inFile := args[0]
+		outFile := strings.TrimSuffix(inFile, filepath.Ext(inFile)) + ".json"
+		if *outPtr != "" {
+			outFile = *outPtr
+		}
+		run := func() error {
+			_, err := locate(inFile, outFile, strings.TrimSuffix(inFile, filepath.Ext(inFile)), opts)
+			return err
+		}
+		if *watching {
+			return watch.Run([]string{inFile}, *watchOpts, run)
+		}
+		return run()
+	}
+	if *watching {
+		return cli.Usagef("-watch works on a single sheet")
+	}
+	if *outPtr != "" {
+		return cli.Usagef("-out names the output of a single sheet; use -out-dir for several")
+	}
+
+	inputs, err := batch.Expand(args, isSheet)
+	if err != nil {
+		return err
+	}
+	if err := batch.CheckOutputs(inputs, *outDirPtr, ".json"); err != nil {
+		return err
+	}
+	log.Printf("locating sprites in %v sheets", len(inputs))
+	results := batch.Run(inputs, *jobsPtr, func(in batch.Input) batch.Result {
+		outFile, err := batch.OutputPath(in, *outDirPtr, ".json")
+		if err != nil {
+			return batch.Result{Err: err}
+		}
+		count, err := locate(in.Path, outFile, strings.TrimSuffix(outFile, ".json"), opts)
+		return batch.Result{Output: outFile, Count: count, Err: err}
+	})
+	return batch.PrintSummary(os.Stdout, results, "sprites")
+}
+
+//sheets are the images in a directory other than the outputs of earlier
+//runs: sprites extracted next to their sheet and split -debug images
+func isSheet(path string) bool {
+	if !imagefile.IsImage(path) {
+		return false
+	}
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	return !strings.HasSuffix(base, ".debug") && !isExtractedSprite(base)
+}
+
+//sprites extracted under the default -name are <sheet>_<index>.png, with
+//the sheet itself next to them
+func isExtractedSprite(base string) bool {
+	i := strings.LastIndexByte(base, '_')
+	if i <= 0 || i == len(base)-1 {
+		return false
+	}
+	for _, c := range base[i+1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	matches, _ := filepath.Glob(base[:i] + ".*")
+	for _, m := range matches {
+		if imagefile.IsImage(m) {
+			return true
+		}
+	}
+	return false
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// locate writes the boxes of every sprite in inFile to outFile and returns
+// how many it found. Extracted sprites are named by opts.names with
+// {sheet} set to the last element of extractBase and saved next to it, and
+// where each came from is written to <extractBase>.offsets.json.
+func locate(inFile, outFile, extractBase string, opts locateOptions) (int, error) {
+	path, err := filepath.Abs(inFile)
+	if err != nil {
+		return 0, fmt.Errorf("abs path %v: %v", inFile, err)
+	}
+	log.Printf("reading image at %v", path)
+	img, err := imagefile.Open(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var background color.Color
+	if opts.transparentBg || opts.dedup || opts.mirrors || opts.pivot.Mode != pivot.None {
+		background = algorithm.BackgroundColor(img)
+	}
+	cropOpts := opts.crop
+	if opts.transparentBg {
+		cropOpts.Background = background
+	}
+
+	algorithm := algorithm.FloodFillAlgorithm{
+		Margin:         opts.margin,
+		MinImageHeight: opts.minImageHeight,
+	}
+
+	found := algorithm.FindSpritePixels(img)
+	log.Printf("found %v total sprites, writing json file: %v", len(found), outFile)
+
+	spriteSheet := models.Spritesheet{}
+	rects := make([]image.Rectangle, len(found))
+	for i, pixels := range found {
+		sprite := pixels.Bounds
+		spriteSheet.Sprites = append(spriteSheet.Sprites,
+			models.Sprite{
+				Min: models.Point{X: sprite.Min.X, Y: sprite.Min.Y},
+				Max: models.Point{X: sprite.Max.X, Y: sprite.Max.Y},
+			},
+		)
+		//FindSprites reports the lower right pixel itself as Max
+		rects[i] = image.Rect(sprite.Min.X, sprite.Min.Y, sprite.Max.X+1, sprite.Max.Y+1)
+	}
+	if opts.pivot.Mode != pivot.None {
+		findPivots(img, &spriteSheet, found, rects, opts.pivot, background)
+	}
+	if opts.dedup {
+		spriteSheet.Duplicates = dedup.Find(img, rects, dedup.Options{
+			Background:  background,
+			MaxDistance: opts.nearDistance,
+		})
+		log.Printf("found %v groups of duplicate sprites", len(spriteSheet.Duplicates))
+	}
+	if opts.mirrors {
+		spriteSheet.Mirrors = dedup.FindMirrors(img, rects, dedup.Options{
+			Background:  background,
+			MaxDistance: opts.nearDistance,
+		})
+		log.Printf("found %v sprites mirroring others", len(spriteSheet.Mirrors))
+	}
+	canonical := spriteSheet.Canonical(opts.uniqueNear)
+	mirroredOf := spriteSheet.MirroredOf()
+	var offsets []crop.Offset
+
+	var names []string
+	if opts.extractSprites {
+		for i := range found {
+			names = append(names, opts.names.Execute(naming.Fields{Sheet: filepath.Base(extractBase), Index: i}))
+		}
+		if err := opts.names.Check(names); err != nil {
+			return 0, fmt.Errorf("sprite names from %q: %v", opts.names, err)
+		}
+	}
+
+	for i, pixels := range found {
+		sprite := pixels.Bounds
+		if opts.extractSprites && opts.unique && canonical[i] != i {
+			//canonical[i] < i, so its offset is already there
+			offset := offsets[canonical[i]]
+			offset.X, offset.Y, offset.W, offset.H = rects[i].Min.X, rects[i].Min.Y, rects[i].Dx(), rects[i].Dy()
+			offsets = append(offsets, offset)
+			continue
+		}
+		if opts.extractSprites && opts.unique && mirroredOf[i] >= 0 {
+			//mirrors only refer to lower indexes too
+			offset := offsets[canonical[mirroredOf[i]]]
+			offset.OffsetX = offset.CanvasW - offset.OffsetX - offset.W
+			offset.FlipX = !offset.FlipX
+			offset.X, offset.Y, offset.W, offset.H = rects[i].Min.X, rects[i].Min.Y, rects[i].Dx(), rects[i].Dy()
+			offsets = append(offsets, offset)
+			continue
+		}
+		if opts.extractSprites {
+			fileName := filepath.Join(filepath.Dir(extractBase), names[i])
+			spriteOpts := cropOpts
+			if opts.mask {
+				spriteOpts.Mask = maskOf(pixels)
+			}
+			offset, err := extractSprite(img, sprite, fileName, spriteOpts)
+			if err != nil {
+				return 0, fmt.Errorf("extracting sprite %v: %v", i, err)
+			}
+			offset.File = filepath.ToSlash(names[i])
+			offsets = append(offsets, offset)
+		}
+	}
+	data, err := json.Marshal(spriteSheet)
+	if err != nil {
+		return 0, fmt.Errorf("marshalling sprite sheet metadata: %v", err)
+	}
+
+	if err := output.WriteFile(outFile, data); err != nil {
+		return 0, fmt.Errorf("writing sprite sheet metadata: %v", err)
+	}
+	log.Printf("metadata sheet with %v sprites written to %s", len(spriteSheet.Sprites), outFile)
+	if opts.extractSprites {
+		if err := writeOffsets(extractBase+".offsets.json", offsets); err != nil {
+			return 0, err
+		}
+	}
+	return len(spriteSheet.Sprites), nil
+}
+
+// findPivots sets the pivot of every sprite it can find one for, and warns
+// about the rest.
+func findPivots(img image.Image, sheet *models.Spritesheet, found []algorithm.Sprite, rects []image.Rectangle, opts pivot.Options, background color.Color) {
+	opts.Background = background
+	var missing []string
+	for i, pixels := range found {
+		spriteOpts := opts
+		spriteOpts.Mask = maskOf(pixels)
+		p, ok := pivot.Find(img, rects[i], spriteOpts)
+		if !ok {
+			missing = append(missing, strconv.Itoa(i))
+			continue
+		}
+		sheet.Sprites[i].SetPivot(p, opts.Mode.OnPixel())
+	}
+	if len(missing) > 0 {
+		log.Printf("WARN: no %v pivot found for sprites %v", opts.Mode, strings.Join(missing, ", "))
+	}
+}
+
+// extractSprite saves the sprite whose corner pixels are sprite.Min and
+// sprite.Max in its own image. The caller fills in the offset's File.
+func extractSprite(srcImage image.Image, sprite image.Rectangle, outFile string, opts crop.Options) (crop.Offset, error) {
+	log.Printf("extracting sprite at %v to %v", sprite, outFile)
+	//FindSprites reports the lower right pixel itself as Max
+	rect := image.Rect(sprite.Min.X, sprite.Min.Y, sprite.Max.X+1, sprite.Max.Y+1)
+	newImage, offset := crop.Sprite(srcImage, rect, opts)
+	if err := imagefile.Save(outFile, newImage); err != nil {
+		return crop.Offset{}, err
+	}
+	return crop.Offset{
+		X:       rect.Min.X,
+		Y:       rect.Min.Y,
+		W:       rect.Dx(),
+		H:       rect.Dy(),
+		OffsetX: offset.X,
+		OffsetY: offset.Y,
+		CanvasW: newImage.Bounds().Dx(),
+		CanvasH: newImage.Bounds().Dy(),
+	}, nil
+}
+
+// maskOf reports whether a pixel is one of the sprite's own.
+func maskOf(sprite algorithm.Sprite) func(x, y int) bool {
+	own := make(map[image.Point]bool, len(sprite.Pixels))
+	for _, p := range sprite.Pixels {
+		own[p] = true
+	}
+	return func(x, y int) bool {
+		return own[image.Pt(x, y)]
+	}
+}
+
+func writeOffsets(path string, offsets []crop.Offset) error {
+	return output.Write(path, func(w io.Writer) error {
+		return crop.WriteOffsets(w, offsets)
+	})
+}
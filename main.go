@@ -1,123 +1,104 @@
 package main
 
 import (
-	"encoding/json"
-	"github.com/ilackarms/sprite-locator/algorithm"
-	"github.com/ilackarms/sprite-locator/models"
-	"image/png"
-	"io/ioutil"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
-	"path/filepath"
-	"strconv"
-	"image"
-	"fmt"
-	"errors"
-	"strings"
+
+	"github.com/ilackarms/sprite-locator/animgroup"
+	"github.com/ilackarms/sprite-locator/animsheet"
+	"github.com/ilackarms/sprite-locator/atlasmaker"
+	"github.com/ilackarms/sprite-locator/cli"
+	"github.com/ilackarms/sprite-locator/convert"
+	"github.com/ilackarms/sprite-locator/guide"
+	"github.com/ilackarms/sprite-locator/imagefile"
+	"github.com/ilackarms/sprite-locator/preview"
+	"github.com/ilackarms/sprite-locator/remove-bg-color"
+	"github.com/ilackarms/sprite-locator/sheetmaker"
+	"github.com/ilackarms/sprite-locator/sheetsplitter"
 )
 
-func main() {
-	margin := 4
-	minImageHeight := 0
-	if userMargin := os.Getenv("PIXEL_MARGIN"); userMargin != "" {
-		usrM, err := strconv.Atoi(userMargin)
-		if err != nil {
-			log.Fatalf("%s is not a valid integer. unset PIXEL_MARGIN or give a valid value", userMargin)
-		}
-		margin = usrM
-	}
-	if userMinHeight := os.Getenv("MIN_IMAGE_HEIGHT"); userMinHeight != "" {
-		usrM, err := strconv.Atoi(userMinHeight)
-		if err != nil {
-			log.Fatalf("%s is not a valid integer. unset MIN_IMAGE_HEIGHT or give a valid value", userMinHeight)
-		}
-		minImageHeight = usrM
-	}
-	var extractSprites bool
-	if ex := os.Getenv("EXTRACT_SPRITES"); ex != "" && ex != "false" && ex != "0" {
-		extractSprites = true
-	}
+//pointers, because each command only sets its Run in init
+var commands = []*cli.Command{
+	&locateCommand,
+	&extractCommand,
+	&guide.Command,
+	&sheetmaker.Command,
+	&animgroup.Command,
+	&atlasmaker.Command,
+	&sheetsplitter.Command,
+	&removebg.Command,
+	&convert.Command,
+	&animsheet.Command,
+	&preview.Command,
+}
 
-	args := os.Args
-	if len(args) != 2 && len(args) != 3 {
-		log.Fatal("usage sprite-locator <filename> [<out-file>]")
-	}
-	inFile := args[1]
-	outFile := strings.TrimSuffix(inFile, ".png")+".json"
-	if len(args) == 3 {
-		outFile = args[2]
-	}
-	path, err := filepath.Abs(inFile)
-	if err != nil {
-		log.Fatalf("abs path %v: %v", inFile, err)
-	}
-	log.Printf("reading image at %v", path)
-	reader, err := os.Open(path)
-	if err != nil {
-		log.Fatalf("open %v: %v", path, err)
-	}
-	img, err := png.Decode(reader)
-	if err != nil {
-		log.Fatalf("reading err: %v", err)
-	}
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
 
-	algorithm := algorithm.FloodFillAlgorithm{
-		Margin:         margin,
-		MinImageHeight: minImageHeight,
+func run(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return 2
 	}
-
-	sprites := algorithm.FindSprites(img)
-	log.Printf("found %v total sprites, writing json file: %v", len(sprites), outFile)
-
-	spriteSheet := models.Spritesheet{}
-
-	for i, sprite := range sprites {
-		if extractSprites {
-			fileName := fmt.Sprintf("%v_%v.png", strings.TrimSuffix(inFile, ".png"), i)
-			if err := extractSprite(img, sprite, fileName); err != nil {
-				log.Printf("ERROR: COULD NOT EXTRACT SPRITE: %v", err)
+	name := args[0]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		if len(args) > 1 {
+			if cmd, ok := findCommand(args[1]); ok {
+				return runCommand(cmd, []string{"-h"})
 			}
 		}
-
-		spriteSheet.Sprites = append(spriteSheet.Sprites,
-			models.Sprite{
-				Min: models.Point{sprite.Min.X, sprite.Min.Y},
-				Max: models.Point{sprite.Max.X, sprite.Max.Y},
-			},
-		)
+		printUsage(os.Stdout)
+		return 0
 	}
-	data, err := json.Marshal(spriteSheet)
-	if err != nil {
-		log.Fatalf("marshalling sprite sheet metadata: %v", err)
+	cmd, ok := findCommand(name)
+	if !ok {
+		//sprite-locator <sheet.png> [<out.json>] predates subcommands
+		if imagefile.IsImage(name) {
+			return runCommand(&locateCommand, args)
+		}
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		printUsage(os.Stderr)
+		return 2
 	}
+	return runCommand(cmd, args[1:])
+}
 
-	if err := ioutil.WriteFile(outFile, data, 0644); err != nil {
-		log.Fatalf("writing sprite sheet metadata: %v", err)
+func runCommand(cmd *cli.Command, args []string) int {
+	err := cmd.Run(args)
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &cli.UsageError{}):
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		fmt.Fprintf(os.Stderr, "run 'sprite-locator help %s' for usage\n", cmd.Name)
+		return 2
 	}
-	log.Printf("metadata sheet with %v sprites written to %s", len(spriteSheet.Sprites), outFile)
+	log.Printf("%s: %v", cmd.Name, err)
+	return 1
 }
 
-func extractSprite(srcImage image.Image, sprite image.Rectangle, outFile string) error {
-	log.Printf("extracting srite at %v to %v", sprite, outFile)
-	newImage := image.NewRGBA(srcImage.Bounds())
-
-	// At(Bounds().Min.X, Bounds().Min.Y) returns the upper-left pixel of the grid.
-	// At(Bounds().Max.X-1, Bounds().Max.Y-1) returns the lower-right one.
-	for y := sprite.Min.Y; y < sprite.Max.Y - 1; y++ {
-		for x := sprite.Min.X; x < sprite.Max.X - 1; x++ {
-			newImage.Set(x, y, srcImage.At(x, y))
+func findCommand(name string) (*cli.Command, bool) {
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return cmd, true
 		}
 	}
+	return nil, false
+}
 
-	//create or open file
-	out, err := os.Create(outFile)
-	if err != nil {
-		log.Printf("WARN: creating file: %v", err)
-		//open
-		out, err = os.Open(outFile)
-		if err != nil {
-			return errors.New(fmt.Sprintf("opening existing file: %v", err))
-		}
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "usage: sprite-locator <command> [flags] [args]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.Name, cmd.Summary)
 	}
-	return png.Encode(out, newImage)
-}
\ No newline at end of file
+	fmt.Fprintf(w, "\nrun 'sprite-locator help <command>' for a command's flags\n")
+}
+
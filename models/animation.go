@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Animation is a single timed animation over the sprites of a sheet, such as
+// one imported from an animated GIF or APNG.
+type Animation struct {
+	Name string `json:"name"`
+	//times to play the animation; 0 loops forever
+	Loops  int              `json:"loops"`
+	Frames []AnimationFrame `json:"frames"`
+}
+
+type AnimationFrame struct {
+	//index into the sheet's sprites
+	Sprite int `json:"sprite"`
+	//milliseconds the frame is shown
+	Delay int `json:"delay"`
+}
+
+// Validate checks that every frame refers to a sprite of a sheet with the
+// given number of sprites.
+func (a Animation) Validate(sprites int) error {
+	for i, frame := range a.Frames {
+		if frame.Sprite < 0 || frame.Sprite >= sprites {
+			return fmt.Errorf("%v frame %v: sprite %v is out of range; the sheet has %v sprites", a.Name, i, frame.Sprite, sprites)
+		}
+		if frame.Delay < 0 {
+			return fmt.Errorf("%v frame %v has negative delay %v", a.Name, i, frame.Delay)
+		}
+	}
+	return nil
+}
+
+func ReadAnimation(r io.Reader) (Animation, error) {
+	var animation Animation
+	if err := json.NewDecoder(r).Decode(&animation); err != nil {
+		return Animation{}, fmt.Errorf("decoding animation: %v", err)
+	}
+	return animation, nil
+}
+
+func (a Animation) Write(w io.Writer) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("marshalling animation: %v", err)
+	}
+	_, err = w.Write(data)
+	return err
+}
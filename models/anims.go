@@ -0,0 +1,238 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Anims assigns located sprites to the animations of a diablo-style
+// 8-direction character. Each direction lists sprite indexes, either single
+// ("12") or inclusive ranges ("12..23").
+type Anims struct {
+	Attack Directions `json:"attack"`
+	Idle   Directions `json:"idle"`
+	Walk   Directions `json:"walk"`
+	GetHit Directions `json:"get_hit"`
+	Die    Directions `json:"die"`
+	Spell  Directions `json:"spell"`
+}
+
+type Directions struct {
+	S  []string `json:"s"`
+	Sw []string `json:"sw"`
+	W  []string `json:"w"`
+	Nw []string `json:"nw"`
+	N  []string `json:"n"`
+	Ne []string `json:"ne"`
+	E  []string `json:"e"`
+	Se []string `json:"se"`
+}
+
+// Each calls fn for every animation and direction, named like "Attack.Sw",
+// in the order atlasmaker has always written them.
+func (a Anims) Each(fn func(name string, frameRange []string)) {
+	a.EachDirection(func(animation, direction string, frameRange []string) {
+		fn(animation+"."+direction, frameRange)
+	})
+}
+
+// EachDirection is Each with the animation and direction names kept apart.
+func (a Anims) EachDirection(fn func(animation, direction string, frameRange []string)) {
+	for _, animation := range []struct {
+		name       string
+		directions Directions
+	}{
+		{"Attack", a.Attack},
+		{"Die", a.Die},
+		{"GetHit", a.GetHit},
+		{"Idle", a.Idle},
+		{"Spell", a.Spell},
+		{"Walk", a.Walk},
+	} {
+		animation.directions.Each(func(direction string, frameRange []string) {
+			fn(animation.name, direction, frameRange)
+		})
+	}
+}
+
+// Each calls fn for every direction, clockwise from south.
+func (d Directions) Each(fn func(direction string, frameRange []string)) {
+	fn("S", d.S)
+	fn("Sw", d.Sw)
+	fn("W", d.W)
+	fn("Nw", d.Nw)
+	fn("N", d.N)
+	fn("Ne", d.Ne)
+	fn("E", d.E)
+	fn("Se", d.Se)
+}
+
+// Indexes expands a list of single indexes and "begin..end" ranges.
+func Indexes(frameRange []string) ([]int, error) {
+	var indexes []int
+	for _, r := range frameRange {
+		if strings.Contains(r, "..") {
+			split := strings.Split(r, "..")
+			begin, err := strconv.Atoi(split[0])
+			if err != nil {
+				return nil, err
+			}
+			end, err := strconv.Atoi(split[1])
+			if err != nil {
+				return nil, err
+			}
+			for i := begin; i <= end; i++ {
+				indexes = append(indexes, i)
+			}
+		} else {
+			i, err := strconv.Atoi(r)
+			if err != nil {
+				return nil, err
+			}
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes, nil
+}
+
+// Validate checks that every range parses and only refers to sprites that
+// exist in a sheet of the given size.
+func (a Anims) Validate(sprites int) error {
+	var err error
+	a.Each(func(name string, frameRange []string) {
+		if err != nil {
+			return
+		}
+		indexes, e := Indexes(frameRange)
+		if e != nil {
+			err = fmt.Errorf("%v: %v", name, e)
+			return
+		}
+		for _, i := range indexes {
+			if i < 0 || i >= sprites {
+				err = fmt.Errorf("%v: sprite %v is out of range; the sheet has %v sprites", name, i, sprites)
+				return
+			}
+		}
+	})
+	return err
+}
+
+// Animations names the animations of an Anims in the order of its fields,
+// as written in anims files.
+var Animations = []string{"Attack", "Idle", "Walk", "GetHit", "Die", "Spell"}
+
+// DirectionNames names the directions clockwise from south, as Each does.
+var DirectionNames = []string{"S", "Sw", "W", "Nw", "N", "Ne", "E", "Se"}
+
+// Write encodes the anims indented, for people to edit.
+func (a Anims) Write(w io.Writer) error {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling anims: %v", err)
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func ReadAnims(r io.Reader) (Anims, error) {
+	var anims Anims
+	if err := json.NewDecoder(r).Decode(&anims); err != nil {
+		return Anims{}, fmt.Errorf("decoding anims: %v", err)
+	}
+	return anims, nil
+}
+
+// MirrorDirection returns the direction facing the other way left to right,
+// e.g. ne for nw, matching the case of direction. North and south have none.
+func MirrorDirection(direction string) (string, bool) {
+	mirror, ok := map[string]string{
+		"sw": "se", "se": "sw",
+		"w": "e", "e": "w",
+		"nw": "ne", "ne": "nw",
+	}[strings.ToLower(direction)]
+	if !ok {
+		return "", false
+	}
+	if direction != strings.ToLower(direction) {
+		mirror = strings.ToUpper(mirror[:1]) + mirror[1:]
+	}
+	return mirror, true
+}
+
+// Directions returns the directions of an animation named as Each and
+// EachDirection name it, e.g. "GetHit".
+func (a Anims) Directions(animation string) Directions {
+	return map[string]Directions{
+		"Attack": a.Attack,
+		"Die":    a.Die,
+		"GetHit": a.GetHit,
+		"Idle":   a.Idle,
+		"Spell":  a.Spell,
+		"Walk":   a.Walk,
+	}[animation]
+}
+
+// Get returns the range of a direction named as Each names it, e.g. "Sw".
+func (d Directions) Get(direction string) []string {
+	var frameRange []string
+	d.Each(func(name string, r []string) {
+		if name == direction {
+			frameRange = r
+		}
+	})
+	return frameRange
+}
+
+// Set replaces the range of one direction of an animation, both named as
+// EachDirection names them, and reports whether they exist.
+func (a *Anims) Set(animation, direction string, frameRange []string) bool {
+	directions, ok := map[string]*Directions{
+		"Attack": &a.Attack,
+		"Die":    &a.Die,
+		"GetHit": &a.GetHit,
+		"Idle":   &a.Idle,
+		"Spell":  &a.Spell,
+		"Walk":   &a.Walk,
+	}[animation]
+	if !ok {
+		return false
+	}
+	field, ok := map[string]*[]string{
+		"S":  &directions.S,
+		"Sw": &directions.Sw,
+		"W":  &directions.W,
+		"Nw": &directions.Nw,
+		"N":  &directions.N,
+		"Ne": &directions.Ne,
+		"E":  &directions.E,
+		"Se": &directions.Se,
+	}[direction]
+	if !ok {
+		return false
+	}
+	*field = frameRange
+	return true
+}
+
+// Ranges is the inverse of Indexes: runs of consecutive indexes become
+// "begin..end" ranges.
+func Ranges(indexes []int) []string {
+	var ranges []string
+	for start := 0; start < len(indexes); {
+		end := start
+		for end+1 < len(indexes) && indexes[end+1] == indexes[end]+1 {
+			end++
+		}
+		if end > start {
+			ranges = append(ranges, fmt.Sprintf("%d..%d", indexes[start], indexes[end]))
+		} else {
+			ranges = append(ranges, strconv.Itoa(indexes[start]))
+		}
+		start = end + 1
+	}
+	return ranges
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Atlas is the frame atlas written by atlasmaker and sheetsplitter, in
+// TexturePacker's json array layout.
+type Atlas struct {
+	Frames []Frame `json:"frames"`
+}
+
+type Frame struct {
+	Filename string `json:"filename"`
+	//region of the frame inside the sheet
+	Box     Box  `json:"frame"`
+	Rotated bool `json:"rotated"`
+	Trimmed bool `json:"trimmed"`
+	//where Box sits inside the untrimmed frame
+	SpriteSourceSize Box `json:"spriteSourceSize"`
+	//size of the untrimmed frame
+	SourceSize Size `json:"sourceSize"`
+	//Box holds the pixels of another frame and is drawn mirrored left to right
+	FlipX bool `json:"flipX,omitempty"`
+	//name of the frame this one is a horizontal mirror of
+	MirrorOf string `json:"mirrorOf,omitempty"`
+	//anchor point as a fraction of the frame, as TexturePacker writes it
+	Pivot *Pivot `json:"pivot,omitempty"`
+	//the same point in pixels from the untrimmed frame's upper left corner
+	PivotPixel *Point `json:"pivotPixel,omitempty"`
+	//PivotPixel is a pixel rather than a line between pixels; see Sprite
+	PivotOnPixel bool `json:"pivotOnPixel,omitempty"`
+}
+
+type Box struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type Size struct {
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// NewFrame returns an untrimmed, unrotated frame covering box.
+func NewFrame(filename string, box Box) Frame {
+	return Frame{
+		Filename: filename,
+		Box:      box,
+	}
+}
+
+// BoxOf returns the box covering a located sprite, Max included.
+func BoxOf(sprite Sprite) Box {
+	size := sprite.Size()
+	return Box{
+		X: sprite.Min.X,
+		Y: sprite.Min.Y,
+		W: size.X,
+		H: size.Y,
+	}
+}
+
+// PivotFrom copies a located sprite's pivot, if it has one, mirrored left
+// to right within the sprite's box, Max included, when the frame is drawn
+// flipped.
+func (f *Frame) PivotFrom(sprite Sprite, flip bool) {
+	if sprite.PivotPixel == nil || sprite.Pivot == nil {
+		f.Pivot, f.PivotPixel, f.PivotOnPixel = nil, nil, false
+		return
+	}
+	pixel, pivot := *sprite.PivotPixel, *sprite.Pivot
+	if flip {
+		pixel.X = MirrorPivotX(pixel.X, sprite.Size().X, sprite.PivotOnPixel)
+		pivot.X = 1 - pivot.X
+	}
+	f.Pivot, f.PivotPixel, f.PivotOnPixel = &pivot, &pixel, sprite.PivotOnPixel
+}
+
+func (f Frame) Validate() error {
+	if f.Filename == "" {
+		return fmt.Errorf("frame at %+v has no filename", f.Box)
+	}
+	if f.Box.W < 0 || f.Box.H < 0 {
+		return fmt.Errorf("frame %v has negative size %vx%v", f.Filename, f.Box.W, f.Box.H)
+	}
+	if f.Trimmed {
+		if f.SourceSize.W < f.Box.W || f.SourceSize.H < f.Box.H {
+			return fmt.Errorf("frame %v is trimmed to %vx%v but its source is only %vx%v",
+				f.Filename, f.Box.W, f.Box.H, f.SourceSize.W, f.SourceSize.H)
+		}
+	}
+	return nil
+}
+
+// Validate checks every frame and that no two frames share a filename.
+func (a Atlas) Validate() error {
+	seen := make(map[string]bool)
+	for _, frame := range a.Frames {
+		if err := frame.Validate(); err != nil {
+			return err
+		}
+		if seen[frame.Filename] {
+			return fmt.Errorf("frame %v appears more than once", frame.Filename)
+		}
+		seen[frame.Filename] = true
+	}
+	return nil
+}
+
+func ReadAtlas(r io.Reader) (Atlas, error) {
+	var atlas Atlas
+	if err := json.NewDecoder(r).Decode(&atlas); err != nil {
+		return Atlas{}, fmt.Errorf("decoding atlas: %v", err)
+	}
+	return atlas, nil
+}
+
+// Write encodes the atlas as compact json with no trailing newline.
+func (a Atlas) Write(w io.Writer) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("marshalling atlas: %v", err)
+	}
+	_, err = w.Write(data)
+	return err
+}
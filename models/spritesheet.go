@@ -12,12 +12,123 @@ type Sprite struct {
 	Min Point `json:"min"`
 	//Lower Right pixel
 	Max Point `json:"max"`
+	//anchor point found by locate -pivot, in pixels from Min
+	PivotPixel *Point `json:"pivotPixel,omitempty"`
+	//PivotPixel as a fraction of the sprite's width and height
+	Pivot *Pivot `json:"pivot,omitempty"`
+	//PivotPixel is a pixel, as centroid and marker pivots are, rather than
+	//a line between pixels like the bottom edge feet pivots stand on
+	PivotOnPixel bool `json:"pivotOnPixel,omitempty"`
 }
 
+// Pivot is an anchor point as a fraction of a box's width and height from
+// its upper left corner; {0.5, 1} is the middle of the bottom edge.
+type Pivot struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Size is the sprite's width and height in pixels, Max included.
+func (s Sprite) Size() image.Point {
+	return image.Pt(s.Max.X-s.Min.X+1, s.Max.Y-s.Min.Y+1)
+}
+
+// SetPivot sets both forms of the sprite's pivot from a point in pixels
+// from Min. When onPixel is set, p is the index of a pixel rather than a
+// line between pixels.
+func (s *Sprite) SetPivot(p image.Point, onPixel bool) {
+	pivot := PivotAt(p, s.Size(), onPixel)
+	s.PivotPixel = &Point{X: p.X, Y: p.Y}
+	s.Pivot = &pivot
+	s.PivotOnPixel = onPixel
+}
+
+// PivotAt normalizes a pivot p, in pixels, in a box of size. A pivot on a
+// pixel is normalized to the pixel's center.
+func PivotAt(p, size image.Point, onPixel bool) Pivot {
+	center := 0.0
+	if onPixel {
+		center = 0.5
+	}
+	return Pivot{
+		X: (float64(p.X) + center) / float64(size.X),
+		Y: (float64(p.Y) + center) / float64(size.Y),
+	}
+}
+
+// MirrorPivotX mirrors the x of a pivot left to right in a box width pixels
+// wide: a line between pixels goes to width - x, the pixel at x to
+// width - 1 - x.
+func MirrorPivotX(x, width int, onPixel bool) int {
+	if onPixel {
+		return width - 1 - x
+	}
+	return width - x
+}
+
+// Rect is the rectangle of the sprite's pixels, Max included.
 func (s Sprite) Rect() image.Rectangle {
-	return image.Rect(s.Min.X, s.Min.Y, s.Max.X, s.Max.Y)
+	return image.Rect(s.Min.X, s.Min.Y, s.Max.X+1, s.Max.Y+1)
 }
 
 type Spritesheet struct {
 	Sprites []Sprite `json:"sprites"`
+	//sprites showing the same frame, found by locate -dedup
+	Duplicates []DuplicateGroup `json:"duplicates,omitempty"`
+	//sprites that are horizontal mirrors of others, found by locate -mirrors
+	Mirrors []Mirror `json:"mirrors,omitempty"`
+}
+
+// Mirror records that a sprite shows another one flipped left to right, as
+// east-facing frames of 8-direction sheets often do with west-facing ones.
+type Mirror struct {
+	Sprite int `json:"sprite"`
+	//the lower index of the pair
+	Of    int  `json:"of"`
+	Exact bool `json:"exact"`
+}
+
+// MirroredOf maps every sprite index to the sprite it mirrors, or -1.
+func (s Spritesheet) MirroredOf() []int {
+	of := make([]int, len(s.Sprites))
+	for i := range of {
+		of[i] = -1
+	}
+	for _, m := range s.Mirrors {
+		if m.Sprite >= 0 && m.Sprite < len(of) {
+			of[m.Sprite] = m.Of
+		}
+	}
+	return of
+}
+
+// DuplicateGroup lists sprites of a sheet that show the same frame. The
+// first, lowest index is the one kept when each frame is written once.
+type DuplicateGroup struct {
+	Sprites []int `json:"sprites"`
+	//false when some sprites only match by perceptual hash, e.g. because
+	//their boxes are a pixel apart
+	Exact bool `json:"exact"`
+}
+
+// Canonical maps every sprite index to the sprite standing in for it: the
+// first of its duplicate group, or itself. Groups that only match by
+// perceptual hash are left alone unless near is set, as their sprites may
+// really be different frames.
+func (s Spritesheet) Canonical(near bool) []int {
+	canonical := make([]int, len(s.Sprites))
+	for i := range canonical {
+		canonical[i] = i
+	}
+	for _, group := range s.Duplicates {
+		if !group.Exact && !near {
+			continue
+		}
+		for _, i := range group.Sprites {
+			if i >= 0 && i < len(canonical) {
+				canonical[i] = group.Sprites[0]
+			}
+		}
+	}
+	return canonical
 }
@@ -0,0 +1,194 @@
+package naming
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//output names built from templates like {sheet}/{anim}/{dir}_{frame:04}.png,
+//shared by sprite extraction and the atlas frame names of atlas and split
+
+// Fields are the values a template can refer to. Not every tool knows all
+// of them; each one says which it fills in when parsing.
+type Fields struct {
+	//sheet file name without its extension
+	Sheet string
+	//animation and direction, e.g. attack and s
+	Anim string
+	Dir  string
+	//frame number within the animation, counting from 1
+	Frame int
+	//position of the sprite in the sheet
+	Index int
+}
+
+const (
+	Sheet = "sheet"
+	Anim  = "anim"
+	Dir   = "dir"
+	Frame = "frame"
+	Index = "index"
+)
+
+var numeric = map[string]bool{Frame: true, Index: true}
+
+type part struct {
+	literal string
+	field   string
+	//zero-padded width of a numeric field; 0 for none
+	width int
+	//added to a numeric field, e.g. -1 in {frame-1} to count frames from 0
+	offset int
+}
+
+type Template struct {
+	source string
+	parts  []part
+}
+
+// Parse checks a template against the fields the caller can fill in. A
+// field is written {name}, a number can be shifted with {name-1} or
+// {name+1}, and zero padded with {name:04}.
+func Parse(source string, known ...string) (Template, error) {
+	isKnown := make(map[string]bool)
+	for _, k := range known {
+		isKnown[k] = true
+	}
+	t := Template{source: source}
+	rest := source
+	for rest != "" {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			t.parts = append(t.parts, part{literal: rest})
+			break
+		}
+		if rest[open] == '}' {
+			return Template{}, fmt.Errorf("template %q has an unmatched }", source)
+		}
+		if open > 0 {
+			t.parts = append(t.parts, part{literal: rest[:open]})
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return Template{}, fmt.Errorf("template %q has an unclosed {", source)
+		}
+		p, err := parseField(rest[open+1 : open+end])
+		if err != nil {
+			return Template{}, fmt.Errorf("template %q: %v", source, err)
+		}
+		if !isKnown[p.field] {
+			return Template{}, fmt.Errorf("template %q: {%s} is not available here; use %s", source, p.field, fieldList(known))
+		}
+		t.parts = append(t.parts, p)
+		rest = rest[open+end+1:]
+	}
+	return t, nil
+}
+
+func parseField(s string) (part, error) {
+	name, format, padded := strings.Cut(s, ":")
+	p := part{field: name}
+	if sign := strings.IndexAny(name, "+-"); sign > 0 {
+		p.field = name[:sign]
+		offset, err := strconv.Atoi(name[sign:])
+		if err != nil {
+			return part{}, fmt.Errorf("bad offset %q in {%s}; write it like {%s-1}", name[sign:], s, p.field)
+		}
+		if !numeric[p.field] {
+			return part{}, fmt.Errorf("{%s} is not a number and cannot be shifted", p.field)
+		}
+		p.offset = offset
+	}
+	if p.field == "" {
+		return part{}, fmt.Errorf("empty field {}")
+	}
+	if !padded {
+		return p, nil
+	}
+	if !numeric[p.field] {
+		return part{}, fmt.Errorf("{%s} is not a number and cannot be padded", p.field)
+	}
+	width, err := strconv.Atoi(format)
+	if err != nil || width <= 0 || !strings.HasPrefix(format, "0") {
+		return part{}, fmt.Errorf("bad padding %q in {%s}; write it like {%s:04}", format, s, p.field)
+	}
+	p.width = width
+	return p, nil
+}
+
+func fieldList(fields []string) string {
+	var braced []string
+	for _, f := range fields {
+		braced = append(braced, "{"+f+"}")
+	}
+	return strings.Join(braced, ", ")
+}
+
+func (t Template) String() string {
+	return t.source
+}
+
+func (t Template) Execute(f Fields) string {
+	var b strings.Builder
+	for _, p := range t.parts {
+		switch p.field {
+		case "":
+			b.WriteString(p.literal)
+		case Sheet:
+			b.WriteString(f.Sheet)
+		case Anim:
+			b.WriteString(f.Anim)
+		case Dir:
+			b.WriteString(f.Dir)
+		case Frame:
+			fmt.Fprintf(&b, "%0*d", p.width, f.Frame+p.offset)
+		case Index:
+			fmt.Fprintf(&b, "%0*d", p.width, f.Index+p.offset)
+		}
+	}
+	return b.String()
+}
+
+// Collisions returns an error naming every name that appears more than once.
+func Collisions(names []string) error {
+	counts := make(map[string]int)
+	for _, name := range names {
+		counts[name]++
+	}
+	var dupes []string
+	for name, n := range counts {
+		if n > 1 {
+			dupes = append(dupes, fmt.Sprintf("%v (%d times)", name, n))
+		}
+	}
+	if len(dupes) == 0 {
+		return nil
+	}
+	sort.Strings(dupes)
+	n := len(dupes)
+	if n > 5 {
+		dupes = append(dupes[:5], fmt.Sprintf("and %d more", n-5))
+	}
+	return fmt.Errorf("%d names collide: %s", n, strings.Join(dupes, ", "))
+}
+
+// Names is a template together with whether the user gave it or it is a
+// command's default.
+type Names struct {
+	Template
+	Custom bool
+}
+
+// Check fails on colliding names made with a template the user gave. The
+// built-in templates only warn, because some existing sheets reuse names.
+func (n Names) Check(names []string) error {
+	err := Collisions(names)
+	if err == nil || n.Custom {
+		return err
+	}
+	log.Printf("WARN: %v", err)
+	return nil
+}
@@ -0,0 +1,98 @@
+package naming
+
+import (
+	"strings"
+	"testing"
+)
+
+var all = []string{Sheet, Anim, Dir, Frame, Index}
+
+func TestExecute(t *testing.T) {
+	fields := Fields{Sheet: "hero", Anim: "attack", Dir: "sw", Frame: 3, Index: 41}
+	for source, want := range map[string]string{
+		"{sheet}/{anim}/{dir}_{frame:04}.png": "hero/attack/sw_0003.png",
+		"{frame+1:04}":                        "0004",
+		"{frame-1}":                           "2",
+		"{index-40:03}":                       "001",
+		"{index+10}":                          "51",
+		"{frame:01}":                          "3",
+		"no fields":                           "no fields",
+		"{anim}{dir}":                         "attacksw",
+	} {
+		tmpl, err := Parse(source, all...)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", source, err)
+			continue
+		}
+		if got := tmpl.Execute(fields); got != want {
+			t.Errorf("%q gave %q, want %q", source, got, want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for source, want := range map[string]string{
+		"{frame":           "unclosed {",
+		"{sheet}_{frame":   "unclosed {",
+		"frame}":           "unmatched }",
+		"{}":               "empty field",
+		"{name}":           "{name} is not available here",
+		"{frame-x}":        `bad offset "-x"`,
+		"{frame+1-1}":      `bad offset "+1-1"`,
+		"{anim+1}":         "{anim} is not a number and cannot be shifted",
+		"{dir:04}":         "{dir} is not a number and cannot be padded",
+		"{frame:4}":        `bad padding "4"`,
+		"{frame:00}":       `bad padding "00"`,
+		"{frame:0x}":       `bad padding "0x"`,
+		"{index:04}{dir}}": "unmatched }",
+	} {
+		_, err := Parse(source, all...)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse(%q) = %v, want an error containing %q", source, err, want)
+		}
+	}
+}
+
+func TestParseUnavailableField(t *testing.T) {
+	//extraction knows the sheet and index but not the animations
+	_, err := Parse("{anim}_{index}", Sheet, Index)
+	if err == nil || !strings.Contains(err.Error(), "{anim} is not available here; use {sheet}, {index}") {
+		t.Errorf("got %v, want an error listing {sheet}, {index}", err)
+	}
+	if _, err := Parse("{sheet}_{index}", Sheet, Index); err != nil {
+		t.Errorf("known fields rejected: %v", err)
+	}
+}
+
+func TestCollisions(t *testing.T) {
+	if err := Collisions([]string{"a", "b", "c"}); err != nil {
+		t.Errorf("distinct names collide: %v", err)
+	}
+	err := Collisions([]string{"c", "a", "b", "a", "c", "c"})
+	if err == nil || err.Error() != "2 names collide: a (2 times), c (3 times)" {
+		t.Errorf("got %v", err)
+	}
+
+	var names []string
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		names = append(names, name, name)
+	}
+	err = Collisions(names)
+	if err == nil || !strings.HasPrefix(err.Error(), "7 names collide: ") || !strings.HasSuffix(err.Error(), "e (2 times), and 2 more") {
+		t.Errorf("got %v, want the first 5 of 7 collisions", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tmpl, err := Parse("{anim}", all...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"walk", "walk"}
+	if err := (Names{Template: tmpl}).Check(names); err != nil {
+		t.Errorf("a default template failed on collisions instead of warning: %v", err)
+	}
+	if err := (Names{Template: tmpl, Custom: true}).Check(names); err == nil {
+		t.Error("a custom template with colliding names passed")
+	}
+}
@@ -0,0 +1,137 @@
+package output
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+//writes every output file of every tool: through a temp file renamed into
+//place, so an interrupted run or a failed encode never leaves half a png
+//behind, and a running -watch or game never reads a partial atlas
+
+// Mode decides what happens to files that already exist.
+type Mode int
+
+const (
+	//replace existing files, unless they are read-only
+	Overwrite Mode = iota
+	//leave existing files alone and fail
+	NoClobber
+	//replace existing files, read-only ones included
+	Force
+)
+
+//set from -force and -no-clobber; one command runs per process
+var mode = Overwrite
+
+// Flags adds -force and -no-clobber to a command's flags.
+func Flags(fs *flag.FlagSet) {
+	fs.Bool("force", false, "overwrite existing output files, even read-only ones")
+	fs.Bool("no-clobber", false, "fail instead of overwriting an existing output file")
+}
+
+// FromFlags sets the mode from the flags added by Flags, once they are
+// parsed. It fails if both are set, or if -no-clobber is set on a command
+// running with -watch, whose every rebuild would fail on the files written
+// by the first.
+func FromFlags(fs *flag.FlagSet) error {
+	force := fs.Lookup("force").Value.String() == "true"
+	noClobber := fs.Lookup("no-clobber").Value.String() == "true"
+	watching := fs.Lookup("watch") != nil && fs.Lookup("watch").Value.String() == "true"
+	switch {
+	case force && noClobber:
+		return fmt.Errorf("-force and -no-clobber cannot be used together")
+	case noClobber && watching:
+		return fmt.Errorf("-no-clobber cannot be used with -watch, which rewrites its outputs on every change")
+	case force:
+		SetMode(Force)
+	case noClobber:
+		SetMode(NoClobber)
+	default:
+		SetMode(Overwrite)
+	}
+	return nil
+}
+
+func SetMode(m Mode) {
+	mode = m
+}
+
+// Write creates path, and its parent directories, with what fn writes. The
+// file only appears, or replaces the old one, once fn and every write have
+// succeeded.
+func Write(path string, fn func(w io.Writer) error) error {
+	perm, err := checkExisting(path)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating %v: %v", dir, err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating %v: %v", path, err)
+	}
+	//removes the temp file unless it was renamed into place
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	buffered := bufio.NewWriter(tmp)
+	if err := fn(buffered); err != nil {
+		return fmt.Errorf("writing %v: %v", path, err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("writing %v: %v", path, err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return fmt.Errorf("writing %v: %v", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing %v: %v", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("writing %v: %v", path, err)
+	}
+	committed = true
+	return nil
+}
+
+// WriteFile is Write for data already in memory.
+func WriteFile(path string, data []byte) error {
+	return Write(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// checkExisting applies the mode to a file already at path and returns the
+// permissions the new file gets: those of the old one, or 0644.
+func checkExisting(path string) (os.FileMode, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return 0644, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("checking %v: %v", path, err)
+	}
+	if info.IsDir() {
+		return 0, fmt.Errorf("cannot write %v: it is a directory", path)
+	}
+	switch {
+	case mode == NoClobber:
+		return 0, fmt.Errorf("%v already exists; remove it or drop -no-clobber", path)
+	case mode != Force && info.Mode().Perm()&0200 == 0:
+		return 0, fmt.Errorf("%v is read-only; use -force to replace it", path)
+	}
+	return info.Mode().Perm(), nil
+}
@@ -0,0 +1,83 @@
+package packer
+
+import "image"
+
+//Guillotine keeps disjoint free rectangles, cutting the one a sprite goes
+//into in two with a single straight cut
+
+// splitRule decides whether the cut under a placed rectangle runs the full
+// width of the free rectangle, given the leftover width and height.
+type splitRule func(placed image.Point, leftover image.Point) bool
+
+func shorterLeftoverAxis(_ image.Point, leftover image.Point) bool {
+	return leftover.X <= leftover.Y
+}
+
+func minimizeArea(placed image.Point, leftover image.Point) bool {
+	return placed.X*leftover.Y > leftover.X*placed.Y
+}
+
+type guillotine struct {
+	free  []image.Rectangle
+	fit   fit
+	split splitRule
+}
+
+func newGuillotine(f fit, split splitRule) func(bin image.Point) Packer {
+	return func(bin image.Point) Packer {
+		return &guillotine{free: []image.Rectangle{{Max: bin}}, fit: f, split: split}
+	}
+}
+
+func (g *guillotine) Insert(size image.Point) (image.Point, bool) {
+	best := -1
+	var bestScore, bestTie int
+	for i, free := range g.free {
+		if free.Dx() < size.X || free.Dy() < size.Y {
+			continue
+		}
+		score, tie := g.fit(free, size)
+		if best < 0 || score < bestScore || (score == bestScore && tie < bestTie) {
+			best, bestScore, bestTie = i, score, tie
+		}
+	}
+	if best < 0 {
+		return image.Point{}, false
+	}
+	free := g.free[best]
+	g.free = append(g.free[:best], g.free[best+1:]...)
+
+	p := free.Min
+	corner := p.Add(size)
+	var below, right image.Rectangle
+	if g.split(size, image.Pt(free.Max.X-corner.X, free.Max.Y-corner.Y)) {
+		below = image.Rect(free.Min.X, corner.Y, free.Max.X, free.Max.Y)
+		right = image.Rect(corner.X, free.Min.Y, free.Max.X, corner.Y)
+	} else {
+		below = image.Rect(free.Min.X, corner.Y, corner.X, free.Max.Y)
+		right = image.Rect(corner.X, free.Min.Y, free.Max.X, free.Max.Y)
+	}
+	for _, r := range []image.Rectangle{below, right} {
+		if !r.Empty() {
+			g.add(r)
+		}
+	}
+	return p, true
+}
+
+// add keeps r as a free rectangle, merged with a neighbour it forms a
+// rectangle with.
+func (g *guillotine) add(r image.Rectangle) {
+	for i, other := range g.free {
+		sameColumn := r.Min.X == other.Min.X && r.Max.X == other.Max.X &&
+			(r.Max.Y == other.Min.Y || other.Max.Y == r.Min.Y)
+		sameRow := r.Min.Y == other.Min.Y && r.Max.Y == other.Max.Y &&
+			(r.Max.X == other.Min.X || other.Max.X == r.Min.X)
+		if sameColumn || sameRow {
+			g.free = append(g.free[:i], g.free[i+1:]...)
+			g.add(r.Union(other))
+			return
+		}
+	}
+	g.free = append(g.free, r)
+}
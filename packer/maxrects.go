@@ -0,0 +1,123 @@
+package packer
+
+import "image"
+
+//MaxRects keeps every maximal free rectangle, overlapping each other, and
+//places each sprite in the one that fits it best
+
+// fit scores placing a rectangle of size in the top left corner of free;
+// lower is better, and the second score breaks ties.
+type fit func(free image.Rectangle, size image.Point) (int, int)
+
+//the smaller leftover side is as short as possible
+func bestShortSideFit(free image.Rectangle, size image.Point) (int, int) {
+	dx, dy := free.Dx()-size.X, free.Dy()-size.Y
+	return minInt(dx, dy), maxInt(dx, dy)
+}
+
+//the larger leftover side is as short as possible
+func bestLongSideFit(free image.Rectangle, size image.Point) (int, int) {
+	dx, dy := free.Dx()-size.X, free.Dy()-size.Y
+	return maxInt(dx, dy), minInt(dx, dy)
+}
+
+//as little of the free rectangle is left over as possible
+func bestAreaFit(free image.Rectangle, size image.Point) (int, int) {
+	dx, dy := free.Dx()-size.X, free.Dy()-size.Y
+	return free.Dx()*free.Dy() - size.X*size.Y, minInt(dx, dy)
+}
+
+//the bottom edge is as high up as possible, then as far left
+func bottomLeft(free image.Rectangle, size image.Point) (int, int) {
+	return free.Min.Y + size.Y, free.Min.X
+}
+
+type maxRects struct {
+	free []image.Rectangle
+	fit  fit
+}
+
+func newMaxRects(f fit) func(bin image.Point) Packer {
+	return func(bin image.Point) Packer {
+		return &maxRects{free: []image.Rectangle{{Max: bin}}, fit: f}
+	}
+}
+
+func (m *maxRects) Insert(size image.Point) (image.Point, bool) {
+	best := -1
+	var bestScore, bestTie int
+	for i, free := range m.free {
+		if free.Dx() < size.X || free.Dy() < size.Y {
+			continue
+		}
+		score, tie := m.fit(free, size)
+		if best < 0 || score < bestScore || (score == bestScore && tie < bestTie) {
+			best, bestScore, bestTie = i, score, tie
+		}
+	}
+	if best < 0 {
+		return image.Point{}, false
+	}
+	placed := image.Rectangle{Min: m.free[best].Min, Max: m.free[best].Min.Add(size)}
+	m.split(placed)
+	return placed.Min, true
+}
+
+// split replaces every free rectangle placed overlaps with the up to four
+// maximal rectangles left around it, and drops free rectangles inside
+// others.
+func (m *maxRects) split(placed image.Rectangle) {
+	var kept, added []image.Rectangle
+	for _, free := range m.free {
+		if !free.Overlaps(placed) {
+			kept = append(kept, free)
+			continue
+		}
+		if placed.Min.X > free.Min.X {
+			added = append(added, image.Rect(free.Min.X, free.Min.Y, placed.Min.X, free.Max.Y))
+		}
+		if placed.Max.X < free.Max.X {
+			added = append(added, image.Rect(placed.Max.X, free.Min.Y, free.Max.X, free.Max.Y))
+		}
+		if placed.Min.Y > free.Min.Y {
+			added = append(added, image.Rect(free.Min.X, free.Min.Y, free.Max.X, placed.Min.Y))
+		}
+		if placed.Max.Y < free.Max.Y {
+			added = append(added, image.Rect(free.Min.X, placed.Max.Y, free.Max.X, free.Max.Y))
+		}
+	}
+	//only the new rectangles can be inside another or contain one
+	var fresh []image.Rectangle
+	for i, r := range added {
+		contained := false
+		for _, other := range kept {
+			if r.In(other) {
+				contained = true
+				break
+			}
+		}
+		for j, other := range added {
+			if i != j && r.In(other) && (r != other || j < i) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			fresh = append(fresh, r)
+		}
+	}
+	m.free = m.free[:0]
+	for _, r := range kept {
+		contained := false
+		for _, other := range fresh {
+			if r.In(other) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			m.free = append(m.free, r)
+		}
+	}
+	m.free = append(m.free, fresh...)
+}
@@ -0,0 +1,229 @@
+package packer
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+)
+
+//places sprites on as small a texture as possible. Every algorithm here
+//follows Jukka Jylänki's "A Thousand Ways to Pack the Bin"; Pack runs all
+//of them on several sprite orders and texture widths and keeps the best.
+
+// Packer places rectangles one at a time in a bin of fixed size.
+type Packer interface {
+	// Insert returns the top left corner of a rectangle of the given size,
+	// or false if it no longer fits.
+	Insert(size image.Point) (image.Point, bool)
+}
+
+// Algorithm is a packer with one choice of heuristics.
+type Algorithm struct {
+	Name string
+	New  func(bin image.Point) Packer
+}
+
+var Algorithms = []Algorithm{
+	{"maxrects-bssf", newMaxRects(bestShortSideFit)},
+	{"maxrects-blsf", newMaxRects(bestLongSideFit)},
+	{"maxrects-baf", newMaxRects(bestAreaFit)},
+	{"maxrects-bl", newMaxRects(bottomLeft)},
+	{"skyline-bl", newSkyline(false)},
+	{"skyline-minwaste", newSkyline(true)},
+	{"guillotine-baf-slas", newGuillotine(bestAreaFit, shorterLeftoverAxis)},
+	{"guillotine-bssf-slas", newGuillotine(bestShortSideFit, shorterLeftoverAxis)},
+	{"guillotine-baf-minas", newGuillotine(bestAreaFit, minimizeArea)},
+}
+
+// Options controls the texture sprites are packed on.
+type Options struct {
+	//transparent pixels between sprites
+	Padding int
+	//round the texture up to power-of-two dimensions
+	PowerOfTwo bool
+	//largest texture width or height; zero is unlimited
+	MaxSize int
+}
+
+// Result is where Pack put every rectangle.
+type Result struct {
+	Algorithm string
+	//size of the texture
+	Size image.Point
+	//top left corner of each rectangle, in the order they were given
+	Positions []image.Point
+}
+
+//sprites are inserted largest first; which measure of largest works best
+//depends on the sheet
+var orders = []func(a, b image.Point) bool{
+	func(a, b image.Point) bool { return a.X*a.Y > b.X*b.Y },
+	func(a, b image.Point) bool { return maxSide(a) > maxSide(b) },
+	func(a, b image.Point) bool { return a.Y > b.Y || (a.Y == b.Y && a.X > b.X) },
+}
+
+//texture widths tried when they are not powers of two
+const widthSteps = 24
+
+// Pack places rectangles of the given sizes without overlap on the smallest
+// texture it finds, trying every algorithm. Ties go to the squarer texture.
+func Pack(sizes []image.Point, opts Options) (Result, error) {
+	padded := make([]image.Point, len(sizes))
+	var maxWidth, maxHeight, sumWidth, sumHeight, area int
+	for i, size := range sizes {
+		padded[i] = size.Add(image.Pt(opts.Padding, opts.Padding))
+		if size.X > maxWidth {
+			maxWidth = size.X
+		}
+		if size.Y > maxHeight {
+			maxHeight = size.Y
+		}
+		sumWidth += padded[i].X
+		sumHeight += padded[i].Y
+		area += padded[i].X * padded[i].Y
+	}
+	if opts.MaxSize > 0 && (maxWidth > opts.MaxSize || maxHeight > opts.MaxSize) {
+		return Result{}, fmt.Errorf("a %vx%v sprite is larger than the %v pixel texture limit", maxWidth, maxHeight, opts.MaxSize)
+	}
+	binHeight := sumHeight
+	if opts.MaxSize > 0 && binHeight > opts.MaxSize+opts.Padding {
+		binHeight = opts.MaxSize + opts.Padding
+	}
+
+	var best Result
+	bestArea := -1
+	for _, width := range widths(maxWidth, sumWidth, area, opts) {
+		//the padding right of the last column is cut off again
+		bin := image.Pt(width+opts.Padding, binHeight)
+		for _, algorithm := range Algorithms {
+			for _, order := range orders {
+				positions, used, ok := packOnce(algorithm, bin, padded, order)
+				if !ok {
+					continue
+				}
+				size := used.Sub(image.Pt(opts.Padding, opts.Padding))
+				if opts.PowerOfTwo {
+					size = image.Pt(nextPowerOfTwo(size.X), nextPowerOfTwo(size.Y))
+				}
+				if opts.MaxSize > 0 && (size.X > opts.MaxSize || size.Y > opts.MaxSize) {
+					continue
+				}
+				if a := size.X * size.Y; bestArea < 0 || a < bestArea || (a == bestArea && maxSide(size) < maxSide(best.Size)) {
+					best = Result{Algorithm: algorithm.Name, Size: size, Positions: positions}
+					bestArea = a
+				}
+			}
+		}
+	}
+	if bestArea < 0 {
+		return Result{}, fmt.Errorf("%v sprites do not fit on a %vx%v texture", len(sizes), opts.MaxSize, opts.MaxSize)
+	}
+	return best, nil
+}
+
+//packOnce inserts every rectangle, in order, and returns where they went
+//and the size of the area they cover
+func packOnce(algorithm Algorithm, bin image.Point, sizes []image.Point, less func(a, b image.Point) bool) ([]image.Point, image.Point, bool) {
+	order := make([]int, len(sizes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return less(sizes[order[a]], sizes[order[b]])
+	})
+	packer := algorithm.New(bin)
+	positions := make([]image.Point, len(sizes))
+	var used image.Point
+	for _, i := range order {
+		if sizes[i].X == 0 || sizes[i].Y == 0 {
+			//takes up no room
+			continue
+		}
+		p, ok := packer.Insert(sizes[i])
+		if !ok {
+			return nil, image.Point{}, false
+		}
+		positions[i] = p
+		corner := p.Add(sizes[i])
+		used.X = maxInt(used.X, corner.X)
+		used.Y = maxInt(used.Y, corner.Y)
+	}
+	return positions, used, true
+}
+
+//widths returns the texture widths worth trying: from the widest sprite to
+//every sprite in one row
+func widths(maxWidth, sumWidth, area int, opts Options) []int {
+	upper := sumWidth
+	if opts.MaxSize > 0 && upper > opts.MaxSize {
+		upper = opts.MaxSize
+	}
+	if upper < maxWidth {
+		upper = maxWidth
+	}
+	var ws []int
+	if opts.PowerOfTwo {
+		for w := nextPowerOfTwo(maxWidth); ; w *= 2 {
+			if opts.MaxSize > 0 && w > opts.MaxSize {
+				break
+			}
+			ws = append(ws, w)
+			if w >= upper {
+				break
+			}
+		}
+		return ws
+	}
+	if upper-maxWidth <= widthSteps {
+		for w := maxWidth; w <= upper; w++ {
+			ws = append(ws, w)
+		}
+		return ws
+	}
+	//square-ish textures are most often the smallest, so the widths near
+	//the square root of the area are tried too
+	square := int(math.Ceil(math.Sqrt(float64(area))))
+	seen := make(map[int]bool)
+	add := func(w int) {
+		if w >= maxWidth && w <= upper && !seen[w] {
+			seen[w] = true
+			ws = append(ws, w)
+		}
+	}
+	for step := 0; step <= widthSteps; step++ {
+		add(maxWidth + (upper-maxWidth)*step/widthSteps)
+		add(square * (widthSteps/2 + step) / widthSteps)
+	}
+	sort.Ints(ws)
+	return ws
+}
+
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p *= 2
+	}
+	return p
+}
+
+func maxSide(p image.Point) int {
+	if p.X > p.Y {
+		return p.X
+	}
+	return p.Y
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,137 @@
+package packer
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+)
+
+func randomSizes(r *rand.Rand, n, maxSide int) []image.Point {
+	sizes := make([]image.Point, n)
+	for i := range sizes {
+		sizes[i] = image.Pt(1+r.Intn(maxSide), 1+r.Intn(maxSide))
+	}
+	return sizes
+}
+
+//every rectangle lies inside bounds and overlaps no other, padding
+//included
+func checkPlacement(t *testing.T, name string, sizes, positions []image.Point, bounds image.Point, padding int) {
+	t.Helper()
+	var placed []image.Rectangle
+	for i, size := range sizes {
+		rect := image.Rectangle{Min: positions[i], Max: positions[i].Add(size)}
+		if !rect.In(image.Rectangle{Max: bounds}) {
+			t.Fatalf("%v: rectangle %v is outside %v", name, rect, bounds)
+		}
+		padded := image.Rectangle{Min: rect.Min, Max: rect.Max.Add(image.Pt(padding, padding))}
+		for _, other := range placed {
+			if padded.Overlaps(other) {
+				t.Fatalf("%v: %v and %v are less than %v pixels apart", name, rect, other, padding)
+			}
+		}
+		placed = append(placed, padded)
+	}
+}
+
+func TestAlgorithms(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	bin := image.Pt(100, 10000)
+	for trial := 0; trial < 20; trial++ {
+		sizes := randomSizes(r, 1+r.Intn(60), 40)
+		for _, algorithm := range Algorithms {
+			for _, order := range orders {
+				positions, used, ok := packOnce(algorithm, bin, sizes, order)
+				if !ok {
+					t.Fatalf("%v: %v rectangles at most 40 wide do not fit a %v bin", algorithm.Name, len(sizes), bin)
+				}
+				checkPlacement(t, algorithm.Name, sizes, positions, used, 0)
+				if !used.In(image.Rectangle{Max: bin.Add(image.Pt(1, 1))}) {
+					t.Fatalf("%v: used %v of a %v bin", algorithm.Name, used, bin)
+				}
+			}
+		}
+	}
+}
+
+func TestAlgorithmsReportFullBin(t *testing.T) {
+	for _, algorithm := range Algorithms {
+		packer := algorithm.New(image.Pt(10, 10))
+		if _, ok := packer.Insert(image.Pt(10, 10)); !ok {
+			t.Errorf("%v: a 10x10 rectangle does not fit an empty 10x10 bin", algorithm.Name)
+		}
+		if p, ok := packer.Insert(image.Pt(1, 1)); ok {
+			t.Errorf("%v: placed a rectangle at %v in a full bin", algorithm.Name, p)
+		}
+	}
+}
+
+func TestPackPadding(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	sizes := randomSizes(r, 80, 48)
+	for _, padding := range []int{0, 1, 3} {
+		result, err := Pack(sizes, Options{Padding: padding, MaxSize: 4096})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPlacement(t, result.Algorithm, sizes, result.Positions, result.Size, padding)
+	}
+}
+
+func TestPackPowerOfTwo(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	sizes := randomSizes(r, 50, 30)
+	result, err := Pack(sizes, Options{Padding: 2, PowerOfTwo: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, side := range []int{result.Size.X, result.Size.Y} {
+		if side&(side-1) != 0 {
+			t.Errorf("texture is %v, not a power of two", result.Size)
+		}
+	}
+	checkPlacement(t, result.Algorithm, sizes, result.Positions, result.Size, 2)
+}
+
+func TestPackMaxSize(t *testing.T) {
+	if _, err := Pack([]image.Point{{65, 10}}, Options{MaxSize: 64}); err == nil {
+		t.Error("packed a sprite wider than the texture limit")
+	}
+	if _, err := Pack([]image.Point{{100, 100}, {100, 100}}, Options{MaxSize: 150}); err == nil {
+		t.Error("packed two 100x100 sprites on a 150x150 texture")
+	}
+	//padding right of and below the last sprites is cut off, so exactly
+	//two padded sprites fit
+	result, err := Pack([]image.Point{{50, 50}, {50, 50}}, Options{Padding: 4, MaxSize: 104})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPlacement(t, result.Algorithm, []image.Point{{50, 50}, {50, 50}}, result.Positions, image.Pt(104, 104), 4)
+}
+
+//sprites are never rotated to fit, as the sheets written have no way to
+//say a frame was turned; a tall sprite keeps the texture tall
+func TestPackDoesNotRotate(t *testing.T) {
+	sizes := []image.Point{{40, 10}, {10, 40}}
+	if _, err := Pack(sizes, Options{MaxSize: 39}); err == nil {
+		t.Error("fit a 10x40 sprite under a 39 pixel limit")
+	}
+	result, err := Pack(sizes, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPlacement(t, result.Algorithm, sizes, result.Positions, result.Size, 0)
+	if result.Size.Y < 40 {
+		t.Errorf("a 10x40 sprite fit a %v texture", result.Size)
+	}
+}
+
+func TestPackEmptySprites(t *testing.T) {
+	result, err := Pack([]image.Point{{10, 10}, {0, 5}}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Size != image.Pt(10, 10) {
+		t.Errorf("an empty sprite grew the texture to %v", result.Size)
+	}
+}
@@ -0,0 +1,101 @@
+package packer
+
+import "image"
+
+//Skyline only tracks the height of the packed area along the texture and
+//places each sprite on top of it, which is fast and rarely far from
+//MaxRects on sprites of similar size
+
+type segment struct {
+	x, y, w int
+}
+
+type skyline struct {
+	bin      image.Point
+	segments []segment
+	//pick the spot leaving the least area under the sprite, instead of the
+	//lowest one
+	minWaste bool
+}
+
+func newSkyline(minWaste bool) func(bin image.Point) Packer {
+	return func(bin image.Point) Packer {
+		return &skyline{bin: bin, segments: []segment{{w: bin.X}}, minWaste: minWaste}
+	}
+}
+
+func (s *skyline) Insert(size image.Point) (image.Point, bool) {
+	best := -1
+	var bestY, bestScore, bestTie int
+	for i := range s.segments {
+		y, waste, ok := s.fits(i, size)
+		if !ok {
+			continue
+		}
+		score, tie := y+size.Y, s.segments[i].w
+		if s.minWaste {
+			score, tie = waste, y+size.Y
+		}
+		if best < 0 || score < bestScore || (score == bestScore && tie < bestTie) {
+			best, bestY, bestScore, bestTie = i, y, score, tie
+		}
+	}
+	if best < 0 {
+		return image.Point{}, false
+	}
+	p := image.Pt(s.segments[best].x, bestY)
+	s.raise(best, segment{x: p.X, y: p.Y + size.Y, w: size.X})
+	return p, true
+}
+
+// fits returns how high a rectangle starting at segment i has to sit to
+// clear every segment under it, and the area it leaves empty below.
+func (s *skyline) fits(i int, size image.Point) (int, int, bool) {
+	x := s.segments[i].x
+	if x+size.X > s.bin.X {
+		return 0, 0, false
+	}
+	y := 0
+	for j, left := i, size.X; left > 0; j++ {
+		y = maxInt(y, s.segments[j].y)
+		left -= s.segments[j].w
+	}
+	if y+size.Y > s.bin.Y {
+		return 0, 0, false
+	}
+	waste := 0
+	for j := i; j < len(s.segments) && s.segments[j].x < x+size.X; j++ {
+		seg := s.segments[j]
+		width := minInt(seg.x+seg.w, x+size.X) - seg.x
+		waste += (y - seg.y) * width
+	}
+	return y, waste, true
+}
+
+// raise puts top into the skyline at index i, shortening or dropping the
+// segments it covers, and joins neighbours of the same height.
+func (s *skyline) raise(i int, top segment) {
+	s.segments = append(s.segments[:i], append([]segment{top}, s.segments[i:]...)...)
+	end := top.x + top.w
+	for j := i + 1; j < len(s.segments); {
+		seg := &s.segments[j]
+		if seg.x >= end {
+			break
+		}
+		covered := end - seg.x
+		seg.x += covered
+		seg.w -= covered
+		if seg.w > 0 {
+			break
+		}
+		s.segments = append(s.segments[:j], s.segments[j+1:]...)
+	}
+	for j := 0; j+1 < len(s.segments); {
+		if s.segments[j].y == s.segments[j+1].y {
+			s.segments[j].w += s.segments[j+1].w
+			s.segments = append(s.segments[:j+1], s.segments[j+2:]...)
+			continue
+		}
+		j++
+	}
+}
@@ -0,0 +1,177 @@
+package pivot
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//finds the point a sprite is anchored on, so frames of different sizes
+//line up when played or packed
+
+type Mode string
+
+const (
+	//no pivot
+	None Mode = ""
+	//bottom center of the opaque pixels, where a character stands
+	Feet Mode = "feet"
+	//mean position of the opaque pixels
+	Centroid Mode = "centroid"
+	//a pixel the artist painted in the marker color
+	Marker Mode = "marker"
+)
+
+// OnPixel reports whether the points a mode finds are pixels, as the
+// centroid and marker are, rather than lines between pixels, like the
+// bottom edge feet stand on.
+func (m Mode) OnPixel() bool {
+	return m == Centroid || m == Marker
+}
+
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case None, Feet, Centroid, Marker:
+		return m, nil
+	}
+	return None, fmt.Errorf("unknown pivot %q; use feet, centroid or marker", s)
+}
+
+// ParseColor parses a color written as rrggbb or rrggbbaa, with or without
+// a leading #.
+func ParseColor(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return nil, fmt.Errorf("color %q is not #rrggbb", s)
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("color %q is not #rrggbb", s)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// Options controls how a sprite's pivot is found.
+type Options struct {
+	Mode Mode
+	//pixels of this color count as transparent; nil when the sheet has alpha
+	Background color.Color
+	//color of the pivot pixel for Marker
+	Marker color.Color
+	//when set, only pixels for which it returns true belong to the sprite
+	Mask func(x, y int) bool
+}
+
+// Find returns the pivot of the sprite at rect of img, in pixels from
+// rect.Min. Feet pivots sit on the bottom edge of the lowest opaque row, so
+// a sprite standing on the bottom of its box has a normalized Y of 1; the
+// other modes return the index of a pixel (see Mode.OnPixel). It
+// returns false when the sprite has no opaque pixels or, for Marker, no
+// pixel of the marker color.
+func Find(img image.Image, rect image.Rectangle, opts Options) (image.Point, bool) {
+	rect = rect.Intersect(img.Bounds())
+	switch opts.Mode {
+	case Feet:
+		return feet(img, rect, opts)
+	case Centroid:
+		return centroid(img, rect, opts)
+	case Marker:
+		return marker(img, rect, opts)
+	}
+	return image.Point{}, false
+}
+
+//feet takes the horizontal middle of the bottom eighth of the sprite, so a
+//single trailing pixel does not pull the pivot sideways
+func feet(img image.Image, rect image.Rectangle, opts Options) (image.Point, bool) {
+	top, bottom := -1, -1
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if opaque(img, x, y, opts) {
+				if top < 0 {
+					top = y
+				}
+				bottom = y
+				break
+			}
+		}
+	}
+	if bottom < 0 {
+		return image.Point{}, false
+	}
+	rows := (bottom - top + 1) / 8
+	if rows < 1 {
+		rows = 1
+	}
+	left, right := rect.Max.X, rect.Min.X-1
+	for y := bottom - rows + 1; y <= bottom; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if opaque(img, x, y, opts) {
+				if x < left {
+					left = x
+				}
+				if x > right {
+					right = x
+				}
+			}
+		}
+	}
+	return image.Pt((left+right+1)/2-rect.Min.X, bottom+1-rect.Min.Y), true
+}
+
+func centroid(img image.Image, rect image.Rectangle, opts Options) (image.Point, bool) {
+	var sumX, sumY, count float64
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if opaque(img, x, y, opts) {
+				//pixel centers
+				sumX += float64(x-rect.Min.X) + 0.5
+				sumY += float64(y-rect.Min.Y) + 0.5
+				count++
+			}
+		}
+	}
+	if count == 0 {
+		return image.Point{}, false
+	}
+	return image.Pt(int(math.Floor(sumX/count)), int(math.Floor(sumY/count))), true
+}
+
+//marker pixels are found anywhere in the box, mask or not, as they are
+//usually painted apart from the sprite
+func marker(img image.Image, rect image.Rectangle, opts Options) (image.Point, bool) {
+	if opts.Marker == nil {
+		return image.Point{}, false
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if sameColor(img.At(x, y), opts.Marker) {
+				return image.Pt(x-rect.Min.X, y-rect.Min.Y), true
+			}
+		}
+	}
+	return image.Point{}, false
+}
+
+func opaque(img image.Image, x, y int, opts Options) bool {
+	if opts.Mask != nil && !opts.Mask(x, y) {
+		return false
+	}
+	c := img.At(x, y)
+	if _, _, _, a := c.RGBA(); a == 0 {
+		return false
+	}
+	return opts.Background == nil || !sameColor(c, opts.Background)
+}
+
+func sameColor(c1, c2 color.Color) bool {
+	r1, g1, b1, a1 := c1.RGBA()
+	r2, g2, b2, a2 := c2.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
@@ -0,0 +1,191 @@
+package preview
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ilackarms/sprite-locator/cli"
+	"github.com/ilackarms/sprite-locator/formats"
+	"github.com/ilackarms/sprite-locator/imagefile"
+	"github.com/ilackarms/sprite-locator/output"
+)
+
+//renders every animation of an atlas as an animated gif or apng, for
+//checking a sheetsplitter yml or an anims file by eye
+
+var Command = cli.Command{
+	Name:    "preview",
+	Summary: "render each animation of an atlas as an animated gif or apng",
+	Usage:   "-image <sheet.png> -atlas <atlas.json> [-out <dir>] [-format gif|apng] [-delay ms] [-anchor bottom|center|pivot|top-left] [-anim name,...]",
+}
+
+func init() {
+	Command.Run = run
+}
+
+type options struct {
+	format string
+	delay  int
+	anchor string
+	loops  int
+	//animation names to render; all when empty
+	only map[string]bool
+}
+
+var anchors = map[string]func(source image.Point, pivot formats.Pivot) image.Point{
+	"bottom": func(source image.Point, _ formats.Pivot) image.Point {
+		return image.Pt(source.X/2, source.Y)
+	},
+	"center": func(source image.Point, _ formats.Pivot) image.Point {
+		return image.Pt(source.X/2, source.Y/2)
+	},
+	"pivot": func(source image.Point, pivot formats.Pivot) image.Point {
+		return image.Pt(int(pivot.X*float64(source.X)+0.5), int(pivot.Y*float64(source.Y)+0.5))
+	},
+	"top-left": func(image.Point, formats.Pivot) image.Point {
+		return image.Point{}
+	},
+}
+
+func run(args []string) error {
+	var opts options
+	fs := cli.NewFlagSet(Command)
+	imagePtr := fs.String("image", "", "sheet image")
+	atlasPtr := fs.String("atlas", "", "atlas json from atlas or split")
+	outPtr := fs.String("out", ".", "output directory")
+	fs.StringVar(&opts.format, "format", "gif", "gif or apng")
+	fs.IntVar(&opts.delay, "delay", 100, "milliseconds per frame")
+	fs.StringVar(&opts.anchor, "anchor", "bottom", "point of every frame kept in place: bottom (center of the bottom edge), center, pivot or top-left")
+	fs.IntVar(&opts.loops, "loops", 0, "times to play each animation; 0 loops forever")
+	animPtr := fs.String("anim", "", "comma separated animations to render, e.g. attack.s,walk.n (default all)")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+	if *imagePtr == "" || *atlasPtr == "" {
+		return cli.Usagef("-image and -atlas are required")
+	}
+	if opts.format != "gif" && opts.format != "apng" {
+		return cli.Usagef("-format must be gif or apng, not %q", opts.format)
+	}
+	if anchors[opts.anchor] == nil {
+		return cli.Usagef("-anchor must be bottom, center, pivot or top-left, not %q", opts.anchor)
+	}
+	if opts.delay <= 0 {
+		return cli.Usagef("-delay must be positive")
+	}
+	if *animPtr != "" {
+		opts.only = make(map[string]bool)
+		for _, name := range strings.Split(*animPtr, ",") {
+			opts.only[strings.TrimSpace(name)] = true
+		}
+	}
+	return preview(*imagePtr, *atlasPtr, *outPtr, opts)
+}
+
+func preview(imgFile, atlasFile, outDir string, opts options) error {
+	sheet, err := imagefile.Open(imgFile)
+	if err != nil {
+		return err
+	}
+	reader, err := os.Open(atlasFile)
+	if err != nil {
+		return fmt.Errorf("open %v: %v", atlasFile, err)
+	}
+	texture, err := formats.ReadAtlas(reader)
+	reader.Close()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("creating %v: %v", outDir, err)
+	}
+
+	ext := ".gif"
+	if opts.format == "apng" {
+		ext = ".png"
+	}
+	var written int
+	for _, animation := range texture.Animations() {
+		if opts.only != nil && !opts.only[animation.Name] {
+			continue
+		}
+		animated := render(sheet, texture, animation, opts)
+		if len(animated.Frames) == 0 {
+			log.Printf("WARN: %v has no frames to render", animation.Name)
+			continue
+		}
+		outFile := filepath.Join(outDir, animation.Name+ext)
+		if err := save(outFile, animated); err != nil {
+			return err
+		}
+		log.Printf("wrote %v frames of %v to %v", len(animated.Frames), animation.Name, outFile)
+		written++
+	}
+	if written == 0 {
+		return fmt.Errorf("%v has no animations to render; frames must be named like attack.s.0000", atlasFile)
+	}
+	return nil
+}
+
+// render draws the frames of one animation onto a canvas shared by all of
+// them, placed so that each frame's anchor lands on the same pixel.
+func render(sheet image.Image, texture formats.Texture, animation formats.Animation, opts options) imagefile.Animated {
+	anchorOf := anchors[opts.anchor]
+	type placed struct {
+		region formats.Region
+		//the untrimmed frame, relative to its anchor
+		bounds image.Rectangle
+	}
+	var frames []placed
+	var canvas image.Rectangle
+	for _, i := range animation.Frames {
+		region := texture.Regions[i]
+		if region.Rotated {
+			log.Printf("WARN: skipping %v: rotated frames are not supported", region.Name)
+			continue
+		}
+		source := region.Source()
+		anchor := anchorOf(source, region.PivotOrCenter())
+		bounds := image.Rectangle{Max: source}.Sub(anchor)
+		frames = append(frames, placed{region: region, bounds: bounds})
+		canvas = canvas.Union(bounds)
+	}
+
+	animated := imagefile.Animated{Loops: opts.loops}
+	size := canvas.Size()
+	for _, frame := range frames {
+		img := image.NewRGBA(image.Rectangle{Max: size})
+		//the trimmed pixels sit at Offset inside the untrimmed frame
+		min := frame.bounds.Min.Sub(canvas.Min).Add(frame.region.Offset)
+		dst := image.Rectangle{Min: min, Max: min.Add(frame.region.Rect.Size())}
+		if frame.region.FlipX {
+			drawFlipped(img, dst, sheet, frame.region.Rect.Min)
+		} else {
+			draw.Draw(img, dst, sheet, frame.region.Rect.Min, draw.Src)
+		}
+		animated.Frames = append(animated.Frames, img)
+		animated.Delays = append(animated.Delays, opts.delay)
+	}
+	return animated
+}
+
+//draw.Draw with the source mirrored left to right
+func drawFlipped(dst *image.RGBA, r image.Rectangle, src image.Image, sp image.Point) {
+	for y := 0; y < r.Dy(); y++ {
+		for x := 0; x < r.Dx(); x++ {
+			dst.Set(r.Max.X-1-x, r.Min.Y+y, src.At(sp.X+x, sp.Y+y))
+		}
+	}
+}
+
+func save(path string, animated imagefile.Animated) error {
+	return output.Write(path, func(w io.Writer) error {
+		return imagefile.EncodeAnimated(w, animated, path)
+	})
+}
@@ -1,28 +1,43 @@
-package main
+package removebg
 
 import (
 	"errors"
 	"fmt"
 	"image"
 	"image/color"
-	"image/png"
 	"log"
-	"os"
 	"path/filepath"
+
+	"github.com/ilackarms/sprite-locator/cli"
+	"github.com/ilackarms/sprite-locator/imagefile"
 )
 
-func main() {
-	args := os.Args
-	if len(args) != 3 {
-		log.Print("usage: process in.png out.png")
-		log.Fatalf("args given: %v", args)
+var Command = cli.Command{
+	Name:    "remove-bg",
+	Summary: "make the white background of a sheet transparent",
+	Usage:   "<in-image> <out-image>",
+}
+
+func init() {
+	Command.Run = run
+}
+
+func run(args []string) error {
+	fs := cli.NewFlagSet(Command)
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return cli.Usagef("remove-bg needs an input and an output image, got %v", fs.Args())
+	}
+	if !imagefile.CanEncode(fs.Arg(1)) {
+		return cli.Usagef("cannot write %v; use a .png, .gif, .jpg, .bmp or .tif extension", fs.Arg(1))
 	}
-	inFile := args[1]
-	outFile := args[2]
-	if err := process(inFile, outFile); err != nil {
-		log.Fatal(err)
+	if err := process(fs.Arg(0), fs.Arg(1)); err != nil {
+		return err
 	}
 	log.Print("OK")
+	return nil
 }
 
 func process(inFile, outFile string) error {
@@ -31,13 +46,9 @@ func process(inFile, outFile string) error {
 		return errors.New(fmt.Sprintf("abs path %v: %v", inFile, err))
 	}
 	log.Printf("reading image at %v", path)
-	reader, err := os.Open(path)
+	srcImage, err := imagefile.Open(path)
 	if err != nil {
-		return errors.New(fmt.Sprintf("open %v: %v", path, err))
-	}
-	srcImage, err := png.Decode(reader)
-	if err != nil {
-		return errors.New(fmt.Sprintf("reading err: %v", err))
+		return err
 	}
 	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
 	bgColor := color.RGBA{R: 255, G: 255, B: 255, A: 0}
@@ -50,18 +61,7 @@ func process(inFile, outFile string) error {
 		newImage.Set(x, y, pixel)
 	})
 
-	//create or open file
-	out, err := os.Create(outFile)
-	if err != nil {
-		log.Printf("WARN: creating file: %v", err)
-		//open
-		out, err = os.Open(outFile)
-		if err != nil {
-			return errors.New(fmt.Sprintf("opening existing file: %v", err))
-		}
-	}
-
-	return png.Encode(out, newImage)
+	return imagefile.Save(outFile, newImage)
 }
 
 func equal(c1, c2 color.Color) bool {
@@ -1,55 +1,132 @@
-package main
+package sheetmaker
 
 import (
-	"os"
 	"fmt"
 	"path/filepath"
 	"log"
-	"image/png"
 	"io/ioutil"
+	"os"
+	"strings"
 	"github.com/emc-advanced-dev/pkg/errors"
 	"github.com/ilackarms/sprite-locator/models"
 	"encoding/json"
 	"image"
-	"flag"
-	"image/color"
+	"image/draw"
 	"math"
+	"github.com/ilackarms/sprite-locator/cli"
+	"github.com/ilackarms/sprite-locator/imagefile"
+	"github.com/ilackarms/sprite-locator/dedup"
+	"github.com/ilackarms/sprite-locator/algorithm"
+	"github.com/ilackarms/sprite-locator/pivot"
+	"github.com/ilackarms/sprite-locator/packer"
+	"github.com/ilackarms/sprite-locator/naming"
+	"github.com/ilackarms/sprite-locator/output"
 )
 
 var spriteMargin int
 
-func main() {
+var Command = cli.Command{
+	Name:    "pack",
+	Summary: "pack boxed sprites or atlas frames onto a new sheet and write its atlas",
+	Usage:   "-image <image> (-boxes <boxes.json> | -atlas <atlas.json>) -out <out.png> [-out-atlas <atlas.json>] [-grid]",
+}
+
+func init() {
+	Command.Run = run
+}
+
+//the name formats.FromSpritesheet gives located boxes, e.g. hero0003
+const defaultName = "{sheet}{index:04}"
+
+type packOptions struct {
+	unique bool
+	//let unique share regions between near duplicates too
+	uniqueNear bool
+	pivot      pivot.Options
+	//lay frames out in equal cells aligned on their pivots instead of
+	//packing them tightly
+	grid   bool
+	packer packer.Options
+	//frame names of -boxes sprites
+	names naming.Names
+}
+
+func run(args []string) error {
 	//take in source image
-	//take in boxes
-	//scan boxes; find largest sprite
-	//largest sprite becomes cell size
-	//new image: cols = sqrt of len(boxes)
-	//   width = cols * largest_sprite.width
-	//   height = rows * largest_sprite.height
-
-	//for each cell:
-	//  locate center of sprite to draw
-	// locate center of destination cell
-	// translate = (c2 - c1)
-	// apply translate to top left pixel, then draw
-
-	imagePtr := flag.String("src", "", "image file")
-	boxesPtr := flag.String("boxes", "", "boxes json file")
-	outPtr := flag.String("out", "", "image file")
-	flag.Parse()
-
-	if *imagePtr == "" || *boxesPtr == "" || *outPtr == "" {
-		fmt.Println("usage: sheetmaker -src <image.png> -boxes <boxes.json> -out <out.png>")
-		fmt.Printf("you gave me: %v\n", os.Args)
-		os.Exit(-1)
-	}
-	if err := makeSheet(*imagePtr, *boxesPtr, *outPtr); err != nil {
-		log.Fatal(err)
+	//take in boxes, or the frames of an atlas
+	//pack every distinct region onto the smallest texture any packer finds
+	//copy each region to where it was packed
+	//write the frames with their new regions as the packed atlas
+
+	//with -grid instead:
+	//  largest sprite around its pivot becomes cell size
+	//  for each cell:
+	//    locate pivot of sprite to draw (its center if it has none)
+	//    locate pivot of destination cell
+	//    translate = (c2 - c1)
+	//    apply translate to top left pixel, then draw
+
+	fs := cli.NewFlagSet(Command)
+	imagePtr := fs.String("image", "", "image file")
+	fs.StringVar(imagePtr, "src", "", "alias of -image")
+	boxesPtr := fs.String("boxes", "", "boxes json file")
+	atlasPtr := fs.String("atlas", "", "atlas json file to pack instead of -boxes, e.g. from atlas -align; names, trimming, pivots and flips are kept")
+	outPtr := fs.String("out", "", "image file")
+	outAtlasPtr := fs.String("out-atlas", "", "atlas json file of the packed sheet (default <out>.atlas.json)")
+	var opts packOptions
+	fs.BoolVar(&opts.unique, "unique", false, "boxes: pack each frame once, using the duplicates listed by locate -dedup or finding them if there are none; frames of duplicates and of sprites listed by locate -mirrors share the region of their original")
+	fs.BoolVar(&opts.uniqueNear, "unique-near", false, "boxes -unique: also share regions between sprites that match by perceptual hash (exact false), not just identical ones")
+	pivotPtr := fs.String("pivot", "", "boxes: find pivots for sprites the boxes json has none for: feet, centroid or marker")
+	markerPtr := fs.String("marker-color", "#ff00ff", "pivot marker: color of the pixel marking each sprite's pivot")
+	namePtr := fs.String("name", defaultName, "boxes: frame name template; fields are {sheet} (boxes file name) and {index} (sprite index)")
+	fs.BoolVar(&opts.grid, "grid", false, "lay frames out in equal cells, aligned on their pivots or on their centers without one, instead of packing them tightly")
+	fs.IntVar(&opts.packer.Padding, "padding", 1, "transparent pixels between packed frames")
+	fs.BoolVar(&opts.packer.PowerOfTwo, "pot", false, "round the packed sheet up to power-of-two dimensions")
+	fs.IntVar(&opts.packer.MaxSize, "max-size", 4096, "largest width or height of the packed sheet; 0 for no limit")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+	var err error
+	if opts.pivot.Mode, err = pivot.ParseMode(*pivotPtr); err != nil {
+		return cli.Usagef("-pivot: %v", err)
+	}
+	if opts.pivot.Mode == pivot.Marker {
+		if opts.pivot.Marker, err = pivot.ParseColor(*markerPtr); err != nil {
+			return cli.Usagef("-marker-color: %v", err)
+		}
+	}
+	tmpl, err := naming.Parse(*namePtr, naming.Sheet, naming.Index)
+	if err != nil {
+		return cli.Usagef("-name: %v", err)
+	}
+	opts.names = naming.Names{Template: tmpl, Custom: *namePtr != defaultName}
+
+	if *imagePtr == "" || *outPtr == "" || (*boxesPtr == "") == (*atlasPtr == "") {
+		return cli.Usagef("-image, -out and one of -boxes or -atlas are required")
+	}
+	if opts.uniqueNear && !opts.unique {
+		return cli.Usagef("-unique-near works with -unique")
+	}
+	if *atlasPtr != "" && (opts.unique || opts.pivot.Mode != pivot.None || *namePtr != defaultName) {
+		return cli.Usagef("-unique, -pivot and -name work on -boxes; atlas frames keep their own")
+	}
+	if opts.packer.Padding < 0 {
+		return cli.Usagef("-padding cannot be negative")
+	}
+	if !imagefile.CanEncode(*outPtr) {
+		return cli.Usagef("cannot write %v; use a .png, .gif, .jpg, .bmp or .tif extension", *outPtr)
+	}
+	if *outAtlasPtr == "" {
+		*outAtlasPtr = strings.TrimSuffix(*outPtr, filepath.Ext(*outPtr)) + ".atlas.json"
+	}
+	if err := makeSheet(*imagePtr, *boxesPtr, *atlasPtr, *outPtr, *outAtlasPtr, opts); err != nil {
+		return err
 	}
 	log.Print("OK")
+	return nil
 }
 
-func makeSheet(imgFile, boxFile, outFile string) error {
+func makeSheet(imgFile, boxFile, atlasFile, outFile, outAtlas string, opts packOptions) error {
 	log.Printf("using: \n\timgFile: %v\n\boxFile: %v\n\toutDir: %v\n\tmargin %v", imgFile, boxFile, outFile, spriteMargin)
 
 	path, err := filepath.Abs(imgFile)
@@ -57,91 +134,271 @@ func makeSheet(imgFile, boxFile, outFile string) error {
 		return errors.New(fmt.Sprintf("abs path %v", imgFile), err)
 	}
 	log.Printf("reading image at %v", path)
-	reader, err := os.Open(path)
+	img, err := imagefile.Open(path)
 	if err != nil {
-		return errors.New(fmt.Sprintf("open %v", path), err)
+		return errors.New(fmt.Sprintf("decoding %v", path), err)
+	}
+
+	var atlas models.Atlas
+	if boxFile != "" {
+		atlas, err = boxesAtlas(img, boxFile, opts)
+	} else {
+		atlas, err = readAtlas(atlasFile)
 	}
-	img, err := png.Decode(reader)
 	if err != nil {
-		return errors.New("reading err", err)
+		return err
+	}
+	if len(atlas.Frames) == 0 {
+		return fmt.Errorf("no sprites or frames to pack")
+	}
+
+	var newImage *image.RGBA
+	if opts.grid {
+		newImage = drawGrid(img, &atlas)
+	} else if newImage, err = drawPacked(img, &atlas, opts.packer); err != nil {
+		return err
+	}
+	log.Printf("drawing new sheet to %v", outFile)
+	if err := imagefile.Save(outFile, newImage); err != nil {
+		return err
 	}
+	if err := atlas.Validate(); err != nil {
+		log.Printf("WARN: %v", err)
+	}
+	log.Printf("writing %v frames to %v", len(atlas.Frames), outAtlas)
+	return output.Write(outAtlas, atlas.Write)
+}
 
+// boxesAtlas makes a frame of every located sprite. With opts.unique,
+// frames of duplicates and mirrors get the region of their original.
+func boxesAtlas(img image.Image, boxFile string, opts packOptions) (models.Atlas, error) {
 	raw, err := ioutil.ReadFile(boxFile)
 	if err != nil {
-		return errors.New("reading box file", err)
+		return models.Atlas{}, errors.New("reading box file", err)
 	}
 	var spriteSheet models.Spritesheet
 	if err := json.Unmarshal(raw, &spriteSheet); err != nil {
-		return errors.New("failed to unmarshal spritesheet", err)
+		return models.Atlas{}, errors.New("failed to unmarshal spritesheet", err)
+	}
+	if opts.pivot.Mode != pivot.None {
+		findPivots(img, &spriteSheet, opts.pivot)
+	}
+	canonical := make([]int, len(spriteSheet.Sprites))
+	mirroredOf := make([]int, len(spriteSheet.Sprites))
+	for i := range canonical {
+		canonical[i], mirroredOf[i] = i, -1
+	}
+	if opts.unique {
+		canonical, mirroredOf = uniqueSprites(img, spriteSheet, opts.uniqueNear)
+	}
+
+	sheet := strings.TrimSuffix(filepath.Base(boxFile), filepath.Ext(boxFile))
+	name := func(i int) string {
+		return opts.names.Execute(naming.Fields{Sheet: sheet, Index: i})
+	}
+	var atlas models.Atlas
+	var filenames []string
+	for i := range spriteSheet.Sprites {
+		original, flip := canonical[i], false
+		if of := mirroredOf[original]; of >= 0 {
+			original, flip = canonical[of], true
+		}
+		source := spriteSheet.Sprites[original]
+		frame := models.NewFrame(name(i), models.BoxOf(source))
+		frame.PivotFrom(source, flip)
+		if flip {
+			frame.FlipX = true
+			frame.MirrorOf = name(original)
+		}
+		atlas.Frames = append(atlas.Frames, frame)
+		filenames = append(filenames, frame.Filename)
 	}
-	return drawNewSheet(img, &spriteSheet, outFile)
+	if err := opts.names.Check(filenames); err != nil {
+		return models.Atlas{}, fmt.Errorf("frame names from %q: %v", opts.names, err)
+	}
+	return atlas, nil
 }
 
-func drawNewSheet(img image.Image, sheet *models.Spritesheet, outFile string) error {
-	//create or open file
-	out, err := os.Create(outFile)
+func readAtlas(atlasFile string) (models.Atlas, error) {
+	reader, err := os.Open(atlasFile)
+	if err != nil {
+		return models.Atlas{}, errors.New("reading atlas file", err)
+	}
+	defer reader.Close()
+	atlas, err := models.ReadAtlas(reader)
 	if err != nil {
-		log.Printf("WARN: creating file: %v", err)
-		//open
-		out, err = os.Open(outFile)
-		if err != nil {
-			return errors.New("opening existing file", err)
+		return models.Atlas{}, fmt.Errorf("%v: %v", atlasFile, err)
+	}
+	for _, frame := range atlas.Frames {
+		if frame.Rotated {
+			return models.Atlas{}, fmt.Errorf("%v: frame %v is rotated; rotated frames cannot be packed", atlasFile, frame.Filename)
 		}
 	}
+	return atlas, nil
+}
 
-	cellWidth, cellHeight := largestSpriteSize(sheet)
-	cellCount := len(sheet.Sprites)
-	cols := int(math.Sqrt(math.Floor(float64(cellCount))))
-	rows := cellCount/cols + 1
-	log.Printf("drawing new sheet to %v", outFile)
+//sets the pivots the boxes json does not have
+func findPivots(img image.Image, sheet *models.Spritesheet, opts pivot.Options) {
+	opts.Background = algorithm.BackgroundColor(img)
+	var found int
+	for i, sprite := range sheet.Sprites {
+		if sprite.PivotPixel != nil {
+			continue
+		}
+		rect := sprite.Rect()
+		if p, ok := pivot.Find(img, rect, opts); ok {
+			sheet.Sprites[i].SetPivot(p, opts.Mode.OnPixel())
+			found++
+		}
+	}
+	log.Printf("found %v %v pivots", found, opts.Mode)
+}
+
+//maps every sprite to the first of its duplicates, and every sprite listed
+//as a mirror to its original; near duplicates count only with near set
+func uniqueSprites(img image.Image, sheet models.Spritesheet, near bool) ([]int, []int) {
+	if len(sheet.Duplicates) == 0 {
+		rects := make([]image.Rectangle, len(sheet.Sprites))
+		for i, sprite := range sheet.Sprites {
+			rects[i] = sprite.Rect()
+		}
+		sheet.Duplicates = dedup.Find(img, rects, dedup.Options{
+			Background:  algorithm.BackgroundColor(img),
+			MaxDistance: dedup.DefaultMaxDistance,
+		})
+	}
+	canonical := sheet.Canonical(near)
+	mirroredOf := sheet.MirroredOf()
+	var unique int
+	for i, c := range canonical {
+		if c == i && mirroredOf[i] < 0 {
+			unique++
+		}
+	}
+	log.Printf("packing %v unique of %v sprites", unique, len(sheet.Sprites))
+	return canonical, mirroredOf
+}
+
+// drawPacked packs the regions of the frames as tightly as it can, and
+// moves the frames to where their regions went. Frames showing the same
+// region share it.
+func drawPacked(img image.Image, atlas *models.Atlas, opts packer.Options) (*image.RGBA, error) {
+	regionOf := make(map[models.Box]int)
+	var regions []models.Box
+	for _, frame := range atlas.Frames {
+		if _, ok := regionOf[frame.Box]; !ok {
+			regionOf[frame.Box] = len(regions)
+			regions = append(regions, frame.Box)
+		}
+	}
+	sizes := make([]image.Point, len(regions))
+	for i, box := range regions {
+		sizes[i] = image.Pt(box.W, box.H)
+	}
+	result, err := packer.Pack(sizes, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	newImage := image.NewRGBA(image.Rect(0, 0, result.Size.X, result.Size.Y))
+	used := 0
+	for i, box := range regions {
+		dst := image.Rectangle{Min: result.Positions[i], Max: result.Positions[i].Add(sizes[i])}
+		draw.Draw(newImage, dst, img, image.Pt(box.X, box.Y), draw.Src)
+		used += box.W * box.H
+	}
+	for i := range atlas.Frames {
+		frame := &atlas.Frames[i]
+		p := result.Positions[regionOf[frame.Box]]
+		frame.Box.X, frame.Box.Y = p.X, p.Y
+	}
+	log.Printf("packed %v regions of %v frames on %vx%v with %v, %.0f%% covered",
+		len(regions), len(atlas.Frames), result.Size.X, result.Size.Y, result.Algorithm,
+		100*float64(used)/math.Max(1, float64(result.Size.X*result.Size.Y)))
+	return newImage, nil
+}
+
+// drawGrid draws every frame into its own cell, all of one size, with the
+// frames' anchors on the same point of each cell. Frames become their
+// untrimmed cells.
+func drawGrid(img image.Image, atlas *models.Atlas) *image.RGBA {
+	cellWidth, cellHeight, cellPivot := cellSize(atlas.Frames)
+	cellCount := len(atlas.Frames)
+	cols := int(math.Ceil(math.Sqrt(float64(cellCount))))
+	rows := (cellCount + cols - 1) / cols
 	log.Printf("cellWidth: %v, cellHeight: %v, rows: %v cols: %v", cellWidth, cellHeight, rows, cols)
 	newImage := image.NewRGBA(image.Rect(0, 0, cellWidth * cols, cellHeight * rows))
-	bgColor := color.RGBA{0,0,0,0}
-	scanImage(img, func(img image.Image, x, y int) {
-		newImage.Set(x, y, img.At(x, y))
-		if _, _, _, a := img.At(x, y).RGBA(); a == 0{
-			newImage.Set(x, y, bgColor)
-		}
-	})
 	//draw each sprite from the original sprite sheet
 	//into the corresponding cell on the new sheet
-	for i, sprite := range sheet.Sprites {
-		spriteCenter := image.Pt((sprite.Max.X+sprite.Min.X)/2, (sprite.Max.Y+sprite.Min.Y)/2)
-		rowIndex := i/rows
+	for i := range atlas.Frames {
+		frame := &atlas.Frames[i]
+		rowIndex := i/cols
 		colIndex := i%cols
 		cellStart := image.Pt(colIndex*cellWidth, rowIndex*cellHeight)
-		cellCenter := image.Pt(cellStart.X+cellWidth/2, cellStart.Y+cellHeight/2)
-		offset := cellCenter.Sub(spriteCenter)
-		for x := sprite.Min.X; x <= sprite.Max.X; x++ {
-			for y := sprite.Min.Y; y <= sprite.Max.Y; y++ {
-				px := img.At(x, y)
-				newImage.Set(x + offset.X, y + offset.Y, px)
+		offset := cellStart.Add(cellPivot).Sub(anchorOf(*frame))
+		box := frame.Box
+		dst := image.Rect(offset.X, offset.Y, offset.X+box.W, offset.Y+box.H)
+		draw.Draw(newImage, dst, img, image.Pt(box.X, box.Y), draw.Src)
+
+		hasPivot := frame.PivotPixel != nil
+		*frame = models.Frame{
+			Filename:     frame.Filename,
+			Box:          models.Box{X: cellStart.X, Y: cellStart.Y, W: cellWidth, H: cellHeight},
+			FlipX:        frame.FlipX,
+			MirrorOf:     frame.MirrorOf,
+			PivotOnPixel: frame.PivotOnPixel,
+		}
+		if hasPivot {
+			//the cell is drawn flipped too
+			p := cellPivot
+			if frame.FlipX {
+				p.X = models.MirrorPivotX(p.X, cellWidth, frame.PivotOnPixel)
 			}
+			pivot := models.PivotAt(p, image.Pt(cellWidth, cellHeight), frame.PivotOnPixel)
+			frame.PivotPixel = &models.Point{X: p.X, Y: p.Y}
+			frame.Pivot = &pivot
+		} else {
+			frame.PivotOnPixel = false
 		}
 	}
-
-	return png.Encode(out, newImage)
+	return newImage
 }
 
-func largestSpriteSize(sheet *models.Spritesheet) (int, int) {
-	var maxWidth, maxHeight int
-	for _, sprite := range sheet.Sprites {
-		if width:= (sprite.Max.X - sprite.Min.X); maxWidth < width {
-			maxWidth = width
-		}
-		if height:= (sprite.Max.Y - sprite.Min.Y); maxHeight < height {
-			maxHeight = height
-		}
+//the point of a frame's region that lands on the cell's pivot, relative to
+//its upper left pixel: its pivot, or its center when it has none
+func anchorOf(frame models.Frame) image.Point {
+	if frame.PivotPixel == nil {
+		return image.Pt(frame.Box.W/2, frame.Box.H/2)
+	}
+	anchor := image.Pt(frame.PivotPixel.X, frame.PivotPixel.Y)
+	if frame.Trimmed {
+		anchor = anchor.Sub(image.Pt(frame.SpriteSourceSize.X, frame.SpriteSourceSize.Y))
 	}
-	return maxWidth, maxHeight
+	//the region holds the pixels unflipped
+	if frame.FlipX {
+		anchor.X = models.MirrorPivotX(anchor.X, frame.Box.W, frame.PivotOnPixel)
+	}
+	return anchor
 }
 
-func scanImage(img image.Image, callback func(img image.Image, x, y int)) {
-	// At(Bounds().Min.X, Bounds().Min.Y) returns the upper-left pixel of the grid.
-	// At(Bounds().Max.X-1, Bounds().Max.Y-1) returns the lower-right one.
-	for x := img.Bounds().Min.X; x < img.Bounds().Max.X-1; x++ {
-		for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y-1; y++ {
-			callback(img, x, y)
+//cells are large enough for every frame with all their anchors on one
+//point, the cell pivot
+func cellSize(frames []models.Frame) (int, int, image.Point) {
+	var left, top, right, bottom int
+	for _, frame := range frames {
+		anchor, size := anchorOf(frame), image.Pt(frame.Box.W, frame.Box.H)
+		if left < anchor.X {
+			left = anchor.X
+		}
+		if top < anchor.Y {
+			top = anchor.Y
+		}
+		if right < size.X-anchor.X {
+			right = size.X - anchor.X
+		}
+		if bottom < size.Y-anchor.Y {
+			bottom = size.Y - anchor.Y
 		}
 	}
-}
\ No newline at end of file
+	return left + right, top + bottom, image.Pt(left, top)
+}
@@ -1,30 +1,33 @@
-package main
+package sheetsplitter
 
 import (
-	"flag"
-	"github.com/Sirupsen/logrus"
 	"io/ioutil"
 	"gopkg.in/yaml.v2"
 	"fmt"
-	"encoding/json"
 	"image"
-	"image/png"
 	"os"
 	"image/color"
 	"github.com/golang/freetype"
 	"log"
 	"strings"
+	"github.com/ilackarms/sprite-locator/models"
+	"github.com/ilackarms/sprite-locator/cli"
+	"github.com/ilackarms/sprite-locator/imagefile"
+	"path/filepath"
+	"runtime"
+	"github.com/ilackarms/sprite-locator/batch"
+	"github.com/ilackarms/sprite-locator/watch"
+	"github.com/ilackarms/sprite-locator/naming"
+	"github.com/ilackarms/sprite-locator/output"
+	"github.com/ilackarms/sprite-locator/dedup"
+	"github.com/ilackarms/sprite-locator/algorithm"
+	"golang.org/x/image/font/gofont/goregular"
+	"image/draw"
 )
 
 //generates an atlas directly from a single spritesheet
 //diablo-formatted
 
-func must(err interface{}) {
-	if err != nil {
-		logrus.Fatal(err)
-	}
-}
-
 var rows = []string{
 	"s",
 	"sw",
@@ -47,24 +50,281 @@ var falloutRows = map[string]int{
 	"se": 2,
 }
 
-func main() {
-	metaFile := flag.String("meta", "", "metadata file that matches []subsheet format")
-	imgFile := flag.String("img", "", "image file for drawing debugging boxes")
-	falloutMode := flag.Bool("f", false, "run in fallout mode instead (6 rows)")
-	flag.Parse()
+var Command = cli.Command{
+	Name:    "split",
+	Summary: "cut a diablo- or fallout-style sheet into an atlas from a yml description of its subsheets",
+	Usage:   "[-f] [-image <sheet.png>] [-extract <dir>] [-out <atlas.json>] [-watch] -meta <sheet.yml> | [-f] [-out-dir <dir>] <yml, directory or pattern>...",
+}
+
+func init() {
+	Command.Run = run
+}
+
+func run(args []string) error {
+	fs := cli.NewFlagSet(Command)
+	metaFile := fs.String("meta", "", "metadata file that matches []subsheet format")
+	imgFile := fs.String("image", "", "image file for drawing debugging boxes")
+	fs.StringVar(imgFile, "img", "", "alias for -image")
+	falloutMode := fs.Bool("f", false, "run in fallout mode instead (6 rows)")
+	outDirPtr := fs.String("out-dir", "", "batch mode: write atlases into a tree mirroring the inputs instead of next to them")
+	jobsPtr := fs.Int("jobs", runtime.NumCPU(), "batch mode: yml files processed at once")
+	outPtr := fs.String("out", "", "atlas json file (default stdout, or <sheet>.atlas.json with -watch)")
+	extractDir := fs.String("extract", "", "also save every frame of the -image sheet into this directory as <frame>.png")
+	watching, watchOpts := watch.Flags(fs)
+	namePtr := fs.String("name", defaultName, "frame name template; fields are {sheet} (yml file name), {anim}, {dir}, {frame} (column, counting from 1) and {index}")
+	mirrors := fs.Bool("mirrors", false, "find frames of the -image sheet that are horizontal mirrors of others and draw them from their original, flipped")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+	tmpl, err := naming.Parse(*namePtr, naming.Sheet, naming.Anim, naming.Dir, naming.Frame, naming.Index)
+	if err != nil {
+		return cli.Usagef("-name: %v", err)
+	}
+	names := naming.Names{Template: tmpl, Custom: *namePtr != defaultName}
+	if *metaFile == "" && fs.NArg() > 0 {
+		if *mirrors {
+			return cli.Usagef("-mirrors needs a single sheet given with -meta and -image")
+		}
+		return runBatch(fs.Args(), *falloutMode, *outDirPtr, *jobsPtr, names)
+	}
 	if *metaFile == "" {
-		must("-meta must be set")
+		return cli.Usagef("-meta must be set")
+	}
+	if *extractDir != "" && *imgFile == "" {
+		return cli.Usagef("-extract needs the sheet given with -image")
+	}
+	if *mirrors && *imgFile == "" {
+		return cli.Usagef("-mirrors needs the sheet given with -image")
+	}
+	if !*watching {
+		return split(*metaFile, *imgFile, *outPtr, *extractDir, *falloutMode, *mirrors, names)
+	}
+	if *outPtr == "" {
+		*outPtr = strings.TrimSuffix(*metaFile, filepath.Ext(*metaFile)) + ".atlas.json"
+	}
+	paths := []string{*metaFile}
+	if *imgFile != "" {
+		paths = append(paths, *imgFile)
+	}
+	return watch.Run(paths, *watchOpts, func() error {
+		return split(*metaFile, *imgFile, *outPtr, *extractDir, *falloutMode, *mirrors, names)
+	})
+}
+
+// split writes the atlas for metaFile to outFile, or stdout when it is
+// empty. Given the sheet image, it also draws the debug image and saves
+// every frame into extractDir, and with mirrors finds mirrored frames.
+func split(metaFile, imgFile, outFile, extractDir string, falloutMode, mirrors bool, names naming.Names) error {
+	atlas, err := splitSheet(metaFile, falloutMode, names)
+	if err != nil {
+		return err
+	}
+	var img image.Image
+	if imgFile != "" {
+		if img, err = imagefile.Open(imgFile); err != nil {
+			return err
+		}
+	}
+	if mirrors {
+		flipMirrors(img, &atlas)
+	}
+	if outFile == "" {
+		err = atlas.Write(os.Stdout)
+	} else if err = output.Write(outFile, atlas.Write); err == nil {
+		log.Printf("wrote %v frames to %v", len(atlas.Frames), outFile)
+	}
+	if err != nil {
+		return err
+	}
+	if img == nil {
+		return nil
+	}
+	if err := drawDebugImage(imgFile, img, atlas); err != nil {
+		return err
+	}
+	if extractDir != "" {
+		return extractFrames(img, atlas, extractDir)
+	}
+	return nil
+}
+
+func extractFrames(img image.Image, atlas models.Atlas, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating %v: %v", dir, err)
+	}
+	for _, frame := range atlas.Frames {
+		box := frame.Box
+		rect := image.Rect(box.X, box.Y, box.X+box.W, box.Y+box.H)
+		sprite := image.NewRGBA(image.Rectangle{Max: rect.Size()})
+		draw.Draw(sprite, sprite.Bounds(), img, rect.Min, draw.Src)
+		if frame.FlipX {
+			flipX(sprite)
+		}
+		//templates may put frames in subdirectories, e.g. {anim}/{dir}_{frame},
+		//which Save creates
+		if err := imagefile.Save(filepath.Join(dir, frame.Filename+".png"), sprite); err != nil {
+			return err
+		}
+	}
+	log.Printf("extracted %v frames to %v", len(atlas.Frames), dir)
+	return nil
+}
+
+func flipX(img *image.RGBA) {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for l, r := b.Min.X, b.Max.X-1; l < r; l, r = l+1, r-1 {
+			left, right := img.At(l, y), img.At(r, y)
+			img.Set(l, y, right)
+			img.Set(r, y, left)
+		}
+	}
+}
+
+// flipMirrors points every frame that shows another one flipped left to
+// right at that frame's region, drawn flipped. Rows already drawn from
+// their mirror are left as they are.
+func flipMirrors(img image.Image, atlas *models.Atlas) {
+	var frames []int
+	var rects []image.Rectangle
+	for i, frame := range atlas.Frames {
+		if frame.FlipX {
+			continue
+		}
+		box := frame.Box
+		frames = append(frames, i)
+		rects = append(rects, image.Rect(box.X, box.Y, box.X+box.W, box.Y+box.H))
+	}
+	mirrors := dedup.FindMirrors(img, rects, dedup.Options{
+		Background:  algorithm.BackgroundColor(img),
+		MaxDistance: dedup.DefaultMaxDistance,
+	})
+	for _, m := range mirrors {
+		original := atlas.Frames[frames[m.Of]]
+		frame := &atlas.Frames[frames[m.Sprite]]
+		frame.Box = original.Box
+		frame.FlipX = true
+		frame.MirrorOf = original.Filename
+	}
+	log.Printf("found %v frames mirroring others", len(mirrors))
+}
+
+func isMetaFile(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".yml" || ext == ".yaml"
+}
+
+// runBatch writes <sheet>.atlas.json for every yml file matched by args.
+func runBatch(args []string, falloutMode bool, outDir string, jobs int, names naming.Names) error {
+	inputs, err := batch.Expand(args, isMetaFile)
+	if err != nil {
+		return err
+	}
+	results := batch.Run(inputs, jobs, func(in batch.Input) batch.Result {
+		atlas, err := splitSheet(in.Path, falloutMode, names)
+		if err != nil {
+			return batch.Result{Err: err}
+		}
+		outFile, err := batch.OutputPath(in, outDir, ".atlas.json")
+		if err != nil {
+			return batch.Result{Err: err}
+		}
+		if err := output.Write(outFile, atlas.Write); err != nil {
+			return batch.Result{Err: err}
+		}
+		return batch.Result{Output: outFile, Count: len(atlas.Frames)}
+	})
+	return batch.PrintSummary(os.Stdout, results, "frames")
+}
+
+// rowsOf returns the directions of a subsheet's rows, top to bottom.
+func rowsOf(subsheet Subsheet, falloutMode bool) ([]string, error) {
+	if len(subsheet.Rows) == 0 {
+		return rows, nil
+	}
+	if falloutMode {
+		return nil, fmt.Errorf("subsheet %v: rows cannot be listed in fallout mode", subsheet.Name)
+	}
+	listed := make(map[string]bool)
+	for _, direction := range subsheet.Rows {
+		if !contains(rows, direction) {
+			return nil, fmt.Errorf("subsheet %v: unknown direction %q in rows; use %v", subsheet.Name, direction, strings.Join(rows, ", "))
+		}
+		if listed[direction] {
+			return nil, fmt.Errorf("subsheet %v: direction %v is listed twice in rows", subsheet.Name, direction)
+		}
+		listed[direction] = true
+	}
+	for _, direction := range rows {
+		if listed[direction] {
+			continue
+		}
+		mirror, ok := models.MirrorDirection(direction)
+		if !ok || !listed[mirror] {
+			return nil, fmt.Errorf("subsheet %v: rows has no %v, and no row to mirror it from", subsheet.Name, direction)
+		}
+	}
+	return subsheet.Rows, nil
+}
+
+// rowOf returns the row drawing direction, and the direction that row faces
+// when it has to be mirrored. rowsOf made sure there is one.
+func rowOf(subsheetRows []string, direction string) (int, string) {
+	for row, d := range subsheetRows {
+		if d == direction {
+			return row, ""
+		}
+	}
+	mirror, _ := models.MirrorDirection(direction)
+	for row, d := range subsheetRows {
+		if d == mirror {
+			return row, mirror
+		}
+	}
+	return 0, ""
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+//the name split has always given frames, e.g. attack.s.0000; {frame} counts
+//from 1 as it does in atlas, so the first column is {frame-1}
+const defaultName = "{anim}.{dir}.{frame-1:04}"
+
+// splitSheet cuts the sheet described by metaFile into frames named by names.
+func splitSheet(metaFile string, falloutMode bool, names naming.Names) (models.Atlas, error) {
+	data, err := ioutil.ReadFile(metaFile)
+	if err != nil {
+		return models.Atlas{}, err
 	}
-	data, err := ioutil.ReadFile(*metaFile)
-	must(err)
 	var sheet Sheet
-	err = yaml.Unmarshal(data, &sheet)
-	must(err)
+	if err := yaml.Unmarshal(data, &sheet); err != nil {
+		return models.Atlas{}, fmt.Errorf("parsing %v: %v", metaFile, err)
+	}
 
-	var atlas Atlas
+	var atlas models.Atlas
+	sheetName := strings.TrimSuffix(filepath.Base(metaFile), filepath.Ext(metaFile))
+	frameName := func(animation, direction string, col int) string {
+		return names.Execute(naming.Fields{
+			Sheet: sheetName,
+			Anim:  animation,
+			Dir:   direction,
+			Frame: col + 1,
+			Index: len(atlas.Frames),
+		})
+	}
 	//create atlas
 	for _, subsheet := range sheet.Subsheets {
 		animationName := subsheet.Name
+		if subsheet.Columns <= 0 {
+			return models.Atlas{}, fmt.Errorf("%v: subsheet %v needs at least one column", metaFile, animationName)
+		}
 		width := (subsheet.End.X - subsheet.Start.X)/ subsheet.Columns
 		//edge case: subsheet only has a single row
 		if subsheet.SingleRow {
@@ -72,70 +332,84 @@ func main() {
 			for col := 0; col < subsheet.Columns; col++ {
 				//fill in every direction with the same column in the atlas
 				for _, direction := range rows {
-					frameName := fmt.Sprintf("%s.%s.%04d", animationName, direction, col)
 					x0 := subsheet.Start.X + col * (width + 1)
 					y0 := subsheet.Start.Y
-					box := Box{
+					box := models.Box{
 						X: x0,
 						Y: y0,
 						W: width,
 						H: height,
 					}
-					atlas.Frames = append(atlas.Frames, Frame{
-						Filename: frameName,
-						Box: box,
-					})
+					atlas.Frames = append(atlas.Frames, models.NewFrame(frameName(animationName, direction, col), box))
 				}
 			}
 			continue
 		}
-		height := (subsheet.End.Y - subsheet.Start.Y) / len(rows)
-		for row, direction := range rows {
-			if *falloutMode {
+		subsheetRows, err := rowsOf(subsheet, falloutMode)
+		if err != nil {
+			return models.Atlas{}, fmt.Errorf("%v: %v", metaFile, err)
+		}
+		height := (subsheet.End.Y - subsheet.Start.Y) / len(subsheetRows)
+		//frames of missing directions, named once their mirror's are known
+		first := len(atlas.Frames)
+		mirrorOf := make(map[int]string)
+		for _, direction := range rows {
+			row, mirror := rowOf(subsheetRows, direction)
+			if falloutMode {
 				row = falloutRows[direction]
 				height = (subsheet.End.Y - subsheet.Start.Y) / 6
 			}
 			y0 := subsheet.Start.Y + row * (height + sheet.RowSpacing)
 			for col := 0; col < subsheet.Columns; col++ {
-				frameName := fmt.Sprintf("%s.%s.%04d", animationName, direction, col)
 				x0 := subsheet.Start.X + col * (width + 1)
 				if subsheet.Reversed {
 					x0 = subsheet.End.X - (col+1) * (width + 1)
 				}
-				box := Box{
+				box := models.Box{
 					X: x0+1,
 					Y: y0+1,
 					W: width-3,
 					H: height-3,
 				}
-				if *falloutMode {
+				if falloutMode {
 					box.W+=2
 					box.H+=2
 				}
-				atlas.Frames = append(atlas.Frames, Frame{
-					Filename: frameName,
-					Box: box,
-				})
+				frame := models.NewFrame(frameName(animationName, direction, col), box)
+				if mirror != "" {
+					frame.FlipX = true
+					mirrorOf[len(atlas.Frames)] = mirror
+				}
+				atlas.Frames = append(atlas.Frames, frame)
+			}
+		}
+		for i, mirror := range mirrorOf {
+			//frames were added row by row in the order of rows
+			col := (i - first) % subsheet.Columns
+			for j := 0; j < len(rows); j++ {
+				if rows[j] == mirror {
+					atlas.Frames[i].MirrorOf = atlas.Frames[first+j*subsheet.Columns+col].Filename
+				}
 			}
 		}
 	}
-	data, err = json.Marshal(atlas)
-	must(err)
-	fmt.Printf("%s", data)
-	if *imgFile != "" {
-		must(drawDebugImage(*imgFile, atlas))
+	//Validate below warns about repeats under the default names
+	if names.Custom {
+		var filenames []string
+		for _, frame := range atlas.Frames {
+			filenames = append(filenames, frame.Filename)
+		}
+		if err := naming.Collisions(filenames); err != nil {
+			return models.Atlas{}, fmt.Errorf("%v: frame names from %q: %v", metaFile, names, err)
+		}
+	}
+	if err := atlas.Validate(); err != nil {
+		log.Printf("WARN: %v: %v", metaFile, err)
 	}
+	return atlas, nil
 }
 
-func drawDebugImage(imgFile string, atlas Atlas) error {
-	reader, err := os.Open(imgFile)
-	if err != nil {
-		return fmt.Errorf("open %v: %v", imgFile, err)
-	}
-	img, _, err := image.Decode(reader)
-	if err != nil {
-		return fmt.Errorf("reading err: %v", err)
-	}
+func drawDebugImage(imgFile string, img image.Image, atlas models.Atlas) error {
 	newImage := image.NewRGBA(img.Bounds())
 	white := color.RGBA{255,255,255,255}
 	scanImage(img, func(img image.Image, x, y int) {
@@ -146,7 +420,7 @@ func drawDebugImage(imgFile string, atlas Atlas) error {
 	})
 	fontBytes, err := ioutil.ReadFile(os.Getenv("HOME")+"/workspace/scratch/fonts/Lato-Regular.ttf")
 	if err != nil {
-		return err
+		fontBytes = goregular.TTF
 	}
 	f, err := freetype.ParseFont(fontBytes)
 	if err != nil {
@@ -164,23 +438,13 @@ func drawDebugImage(imgFile string, atlas Atlas) error {
 		drawBox(newImage, frame.Box, colors, c, i)
 	}
 
-	outFile := strings.Replace(imgFile, ".png", ".debug.png", -1)
-
-	//create or open file
-	out, err := os.Create(outFile)
-	if err != nil {
-		log.Printf("WARN: creating file: %v", err)
-		//open
-		out, err = os.Open(outFile)
-		if err != nil {
-			return fmt.Errorf("opening existing file: %v", err)
-		}
-	}
+	ext := filepath.Ext(imgFile)
+	outFile := imagefile.OutputName(strings.TrimSuffix(imgFile, ext)+".debug"+ext, ".png")
 
-	return png.Encode(out, newImage)
+	return imagefile.Save(outFile, newImage)
 }
 
-func drawBox(img *image.RGBA, box Box, colors []color.Color, context *freetype.Context, i int) {
+func drawBox(img *image.RGBA, box models.Box, colors []color.Color, context *freetype.Context, i int) {
 	c := colors[i%len(colors)]
 	for x := box.X; x < box.X + box.W; x++ {
 		img.Set(x, box.Y, c)
@@ -193,7 +457,7 @@ func drawBox(img *image.RGBA, box Box, colors []color.Color, context *freetype.C
 	context.DrawString(fmt.Sprintf("%v", i), freetype.Pt(box.X, box.Y))
 }
 
-func makeColors(atlas Atlas) []color.Color {
+func makeColors(atlas models.Atlas) []color.Color {
 	boxColors := make([]color.Color, 256 * 256 * 256)
 	i := 0
 	for r := uint8(255); r >= 0; r-- {
@@ -0,0 +1,47 @@
+package sheetsplitter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ilackarms/sprite-locator/naming"
+)
+
+//the atlases in testdata were written by sheetsplitter before it became a
+//subcommand; the default output must not change by a byte, in diablo and
+//in fallout mode
+
+func TestGolden(t *testing.T) {
+	tmpl, err := naming.Parse(defaultName, naming.Sheet, naming.Anim, naming.Dir, naming.Frame, naming.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := naming.Names{Template: tmpl}
+	for _, test := range []struct {
+		meta    string
+		fallout bool
+		golden  string
+	}{
+		{"testdata/azid.yml", false, "testdata/azid.atlas.json"},
+		{"testdata/azid.yml", true, "testdata/azid.fallout.atlas.json"},
+		{"testdata/golem.yml", false, "testdata/golem.atlas.json"},
+		{"testdata/golem.yml", true, "testdata/golem.fallout.atlas.json"},
+	} {
+		atlas, err := splitSheet(test.meta, test.fallout, names)
+		if err != nil {
+			t.Fatalf("%v: %v", test.meta, err)
+		}
+		var got bytes.Buffer
+		if err := atlas.Write(&got); err != nil {
+			t.Fatal(err)
+		}
+		want, err := ioutil.ReadFile(test.golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Bytes(), want) {
+			t.Errorf("%v (fallout %v) differs from %v:\ngot  %.200s\nwant %.200s", test.meta, test.fallout, test.golden, got.Bytes(), want)
+		}
+	}
+}
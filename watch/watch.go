@@ -0,0 +1,138 @@
+package watch
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+//re-runs a command whenever one of its input files changes, so an artist
+//tweaking a yml sees the atlas and debug image refresh
+
+// Options controls how changes are noticed.
+type Options struct {
+	//quiet time after the last change before running again; editors often
+	//write a file several times in a row
+	Debounce time.Duration
+	//when non-zero, stat the files at this interval instead of using
+	//filesystem notifications, e.g. on network drives
+	Poll time.Duration
+}
+
+// Flags adds -watch, -debounce and -poll to a command's flags.
+func Flags(fs *flag.FlagSet) (*bool, *Options) {
+	var opts Options
+	enabled := fs.Bool("watch", false, "keep running and redo the work whenever an input file changes")
+	fs.DurationVar(&opts.Debounce, "debounce", 200*time.Millisecond, "watch: quiet time after a change before redoing the work")
+	fs.DurationVar(&opts.Poll, "poll", 0, "watch: check the files at this interval instead of using filesystem notifications")
+	return enabled, &opts
+}
+
+// Run calls fn once, then again after every change to any of paths. It only
+// returns if the files cannot be watched; errors from fn are logged and
+// watching continues.
+func Run(paths []string, opts Options, fn func() error) error {
+	for i, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("abs path %v: %v", path, err)
+		}
+		paths[i] = abs
+	}
+	run := func() {
+		if err := fn(); err != nil {
+			log.Printf("WARN: %v", err)
+			return
+		}
+		log.Printf("up to date; watching %v for changes", paths)
+	}
+	run()
+
+	changes := make(chan string)
+	if opts.Poll > 0 {
+		go poll(paths, opts.Poll, changes)
+	} else if err := notify(paths, changes); err != nil {
+		return err
+	}
+
+	var pending <-chan time.Time
+	for {
+		select {
+		case path := <-changes:
+			log.Printf("%v changed", path)
+			pending = time.After(opts.Debounce)
+		case <-pending:
+			pending = nil
+			run()
+		}
+	}
+}
+
+// notify watches the directories holding paths rather than the files
+// themselves, because editors that save by renaming a new file into place
+// would otherwise end the watch after the first save.
+func notify(paths []string, changes chan<- string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("starting file watcher: %v", err)
+	}
+	watched := make(map[string]bool)
+	for _, path := range paths {
+		watched[path] = true
+		if err := watcher.Add(filepath.Dir(path)); err != nil {
+			watcher.Close()
+			return fmt.Errorf("watching %v: %v", filepath.Dir(path), err)
+		}
+	}
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if watched[filepath.Clean(event.Name)] && !event.Has(fsnotify.Chmod) {
+					changes <- event.Name
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("WARN: file watcher: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func poll(paths []string, interval time.Duration, changes chan<- string) {
+	stamps := make(map[string]stamp)
+	stampOf := func(path string) stamp {
+		info, err := os.Stat(path)
+		if err != nil {
+			return stamp{}
+		}
+		return stamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	for _, path := range paths {
+		stamps[path] = stampOf(path)
+	}
+	for range time.Tick(interval) {
+		for _, path := range paths {
+			if s := stampOf(path); s != stamps[path] {
+				stamps[path] = s
+				changes <- path
+			}
+		}
+	}
+}